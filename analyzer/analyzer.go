@@ -0,0 +1,164 @@
+// Package analyzer provides an Analyzer that typechecks the templates built in the packages analyzed.
+package analyzer
+
+import (
+	"errors"
+	"fmt"
+	"go/token"
+	"os"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/packages"
+
+	"github.com/motemen/go-template-statictools/templatetypes"
+)
+
+var Analyzer = &analysis.Analyzer{
+	Name: "gotmplcheck",
+	Doc: `statically typecheck templates
+
+The gotmplcheck analyzer finds templates built in the package by ParseFiles, ParseGlob or ParseFS,
+and typechecks them with the types of data passed to Execute/ExecuteTemplate on them.
+Diagnostics are reported at the positions in the template files.`,
+	Run:       run,
+	FactTypes: []analysis.Fact{new(funcMapFact)},
+}
+
+var (
+	flagCatalog string
+	flagSoft    bool
+)
+
+func init() {
+	Analyzer.Flags.StringVar(&flagCatalog, "catalog", "", "comma-separated names of function catalogs to enable")
+	Analyzer.Flags.BoolVar(&flagSoft, "soft", false, "allow undefined functions or templates")
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	pkg := &packages.Package{
+		ID:        pass.Pkg.Path(),
+		Name:      pass.Pkg.Name(),
+		PkgPath:   pass.Pkg.Path(),
+		Fset:      pass.Fset,
+		Syntax:    pass.Files,
+		Types:     pass.Pkg,
+		TypesInfo: pass.TypesInfo,
+	}
+
+	r := reporter{pass: pass, files: map[string]*token.File{}}
+	checker := templatetypes.Checker{
+		AllowUndefinedFuncs:     flagSoft,
+		AllowUndefinedTemplates: flagSoft,
+		// packages are not loaded by the analyzer, but the ones imported are used with the facts
+		Importer: &importer{pass: pass},
+		ReadFile: r.readFile,
+	}
+	if flagCatalog != "" {
+		checker.Catalogs = strings.Split(flagCatalog, ",")
+	}
+
+	// FuncMaps are exported for the packages importing this one
+	for obj, funcMap := range checker.PackageFuncMaps(pkg) {
+		pass.ExportObjectFact(obj, newFuncMapFact(funcMap))
+	}
+
+	sets, err := checker.PackageTemplateSets(pkg)
+	if err != nil {
+		// templates that cannot be resolved are skipped
+		var unresolved *templatetypes.UnresolvedError
+		if !errors.As(err, &unresolved) {
+			return nil, err
+		}
+	}
+	if len(sets) == 0 {
+		return nil, nil
+	}
+
+	bindings := checker.PackageBindings(pkg)
+
+	for i := range sets {
+		set := &sets[i]
+		c, err := checker.CheckTemplateSet(set, bindings)
+		if c == nil {
+			r.reportAt(set.Pos, err.Error())
+			continue
+		}
+		if err == nil {
+			continue
+		}
+		for _, err := range templatetypes.UnwrapErrors(err) {
+			var tcErr templatetypes.TypeCheckError
+			if errors.As(err, &tcErr) && tcErr.Node != nil {
+				name, offset := c.Position(tcErr.Node)
+				if pos := r.templatePos(name, offset); pos.IsValid() {
+					diag := analysis.Diagnostic{Pos: pos, Message: tcErr.Message}
+					for _, caller := range tcErr.Callers {
+						name, offset := c.Position(caller)
+						if pos := r.templatePos(name, offset); pos.IsValid() {
+							diag.Related = append(diag.Related, analysis.RelatedInformation{Pos: pos, Message: "called from " + caller.String()})
+						}
+					}
+					pass.Report(diag)
+					continue
+				}
+			}
+			r.reportAt(set.Pos, err.Error())
+		}
+	}
+
+	return nil, nil
+}
+
+// reporter reports diagnostics in template files, which are added to the file set of the pass as they are read.
+type reporter struct {
+	pass  *analysis.Pass
+	files map[string]*token.File
+}
+
+// readFile reads the template file name for the checker by pass.ReadFile.
+// Drivers allow reading only the files of the package they know of, so template files not among them
+// cannot be read, and are reported at the sets parsing them.
+func (r *reporter) readFile(name string) ([]byte, error) {
+	readFile := r.pass.ReadFile
+	if readFile == nil {
+		// drivers not providing ReadFile
+		readFile = os.ReadFile
+	}
+	content, err := readFile(name)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read template file %s: %w", name, err)
+	}
+
+	if _, ok := r.files[name]; !ok {
+		file := r.pass.Fset.AddFile(name, -1, len(content))
+		file.SetLinesForContent(content)
+		r.files[name] = file
+	}
+	return content, nil
+}
+
+// templatePos returns the position of offset in the template file name, which is read by readFile.
+func (r *reporter) templatePos(name string, offset int) token.Pos {
+	file := r.files[name]
+	if file == nil || offset < 0 || offset > file.Size() {
+		return token.NoPos
+	}
+	return file.Pos(offset)
+}
+
+// reportAt reports a diagnostic at posn in a Go file of the package.
+func (r *reporter) reportAt(posn token.Position, message string) {
+	pos := token.NoPos
+	r.pass.Fset.Iterate(func(f *token.File) bool {
+		if f.Name() == posn.Filename && posn.Offset <= f.Size() {
+			pos = f.Pos(posn.Offset)
+			return false
+		}
+		return true
+	})
+	if !pos.IsValid() && len(r.pass.Files) > 0 {
+		pos = r.pass.Files[0].Package
+	}
+	r.pass.Report(analysis.Diagnostic{Pos: pos, Message: message})
+}
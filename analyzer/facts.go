@@ -0,0 +1,120 @@
+package analyzer
+
+import (
+	"fmt"
+	"go/token"
+	"go/types"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+// funcMapFact is the entries of an exported FuncMap variable, or of the FuncMap returned by an exported function,
+// for the packages importing it, whose source code is not available to the analyzer.
+// Signatures are in the form of type expressions, which refer to the packages of Imports as p0, p1, ... in order.
+// Those that cannot be expressed are empty.
+// Entries are sorted by name and the packages are numbered in order of appearance, as facts are encoded deterministically.
+type funcMapFact struct {
+	Funcs   []funcMapEntry
+	Imports []string
+}
+
+type funcMapEntry struct {
+	Name, Signature string
+}
+
+func (*funcMapFact) AFact() {}
+
+func (f *funcMapFact) String() string {
+	names := make([]string, len(f.Funcs))
+	for i, entry := range f.Funcs {
+		names[i] = entry.Name
+	}
+	return "funcMap(" + strings.Join(names, ", ") + ")"
+}
+
+func newFuncMapFact(funcMap map[string]*types.Signature) *funcMapFact {
+	fact := &funcMapFact{}
+
+	aliases := map[*types.Package]string{}
+	qualifier := func(pkg *types.Package) string {
+		alias, ok := aliases[pkg]
+		if !ok {
+			alias = fmt.Sprintf("p%d", len(fact.Imports))
+			aliases[pkg] = alias
+			fact.Imports = append(fact.Imports, pkg.Path())
+		}
+		return alias
+	}
+
+	names := make([]string, 0, len(funcMap))
+	for name := range funcMap {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		entry := funcMapEntry{Name: name}
+		if sig := funcMap[name]; sig != nil {
+			entry.Signature = types.TypeString(sig, qualifier)
+		}
+		fact.Funcs = append(fact.Funcs, entry)
+	}
+	return fact
+}
+
+// importer provides the packages imported by the package analyzed, and the FuncMaps declared in them by the facts.
+type importer struct {
+	pass     *analysis.Pass
+	packages map[string]*types.Package
+}
+
+func (i *importer) ImportPackage(path string) *types.Package {
+	if i.packages == nil {
+		i.packages = map[string]*types.Package{}
+		var add func(pkg *types.Package)
+		add = func(pkg *types.Package) {
+			if _, ok := i.packages[pkg.Path()]; ok {
+				return
+			}
+			i.packages[pkg.Path()] = pkg
+			for _, imp := range pkg.Imports() {
+				add(imp)
+			}
+		}
+		add(i.pass.Pkg)
+	}
+	return i.packages[path]
+}
+
+func (i *importer) ImportFuncMap(obj types.Object) (map[string]*types.Signature, error) {
+	var fact funcMapFact
+	if !i.pass.ImportObjectFact(obj, &fact) {
+		return nil, fmt.Errorf("cannot resolve FuncMap from %s", obj.Name())
+	}
+
+	// the signatures are evaluated in a scope with the packages they refer to
+	scope := types.NewPackage("facts", "facts")
+	for n, path := range fact.Imports {
+		name := fmt.Sprintf("p%d", n)
+		pkg := i.ImportPackage(path)
+		if pkg == nil && path == obj.Pkg().Path() {
+			pkg = obj.Pkg()
+		}
+		if pkg != nil {
+			scope.Scope().Insert(types.NewPkgName(token.NoPos, scope, name, pkg))
+		}
+	}
+
+	funcMap := map[string]*types.Signature{}
+	for _, entry := range fact.Funcs {
+		var sig *types.Signature
+		if tv, err := types.Eval(token.NewFileSet(), scope, token.NoPos, entry.Signature); err == nil {
+			sig, _ = tv.Type.(*types.Signature)
+		}
+		// the signature is nil if it refers to types not available here
+		funcMap[entry.Name] = sig
+	}
+	return funcMap, nil
+}
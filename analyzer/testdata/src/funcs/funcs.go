@@ -0,0 +1,15 @@
+package funcs
+
+import (
+	"strings"
+	"text/template"
+)
+
+type User struct {
+	Name string
+}
+
+var FuncMap = template.FuncMap{ // want FuncMap:"funcMap\\(name, upper\\)"
+	"upper": strings.ToUpper,
+	"name":  func(u *User) string { return u.Name },
+}
@@ -0,0 +1,20 @@
+package pages
+
+import (
+	"io"
+	"text/template"
+
+	"funcs"
+)
+
+type Page struct {
+	Title string
+	Count int
+	User  *funcs.User
+}
+
+var pages = template.Must(template.New("page.tmpl").Funcs(funcs.FuncMap).ParseFiles("page.tmpl")) // want `cannot read template file .*page.tmpl`
+
+func Render(w io.Writer, p Page) error {
+	return pages.Execute(w, p)
+}
@@ -0,0 +1,47 @@
+// gotmpl-lsp is a language server for Go template files, which speaks LSP over stdio.
+package main
+
+import (
+	"flag"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/motemen/go-template-statictools/templatetypes"
+)
+
+func main() {
+	var (
+		flagDot     = flag.String("dot", "", "`path/to/pkg.type` of template data")
+		flagVerbose = flag.Bool("verbose", false, "enable verbose logging to stderr")
+		flagFuncMap = flag.String("funcmap", "", "comma-separated `path/to/pkg.name` of template FuncMap")
+		flagSoft    = flag.Bool("soft", false, "allow undefined functions or templates")
+		flagHTML    = flag.Bool("html", false, "check templates as html/template")
+		flagCatalog = flag.String("catalog", "", "comma-separated `names` of function catalogs to enable (available: "+strings.Join(templatetypes.Catalogs(), ", ")+")")
+	)
+	flag.Parse()
+
+	// stdout is for the protocol
+	log.SetOutput(os.Stderr)
+	log.SetFlags(0)
+
+	checker := &templatetypes.Checker{
+		DotType:    *flagDot,
+		FuncMapVar: *flagFuncMap,
+		HTML:       *flagHTML,
+		Verbose:    *flagVerbose,
+	}
+	if *flagCatalog != "" {
+		checker.Catalogs = strings.Split(*flagCatalog, ",")
+	}
+	if *flagSoft {
+		checker.AllowUndefinedFuncs = true
+		checker.AllowUndefinedTemplates = true
+	}
+
+	err := newServer(os.Stdin, os.Stdout, checker).serve()
+	if err != nil && err != io.EOF {
+		log.Fatal(err)
+	}
+}
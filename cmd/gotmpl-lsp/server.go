@@ -0,0 +1,527 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go/types"
+	"io"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template/parse"
+
+	"golang.org/x/tools/go/types/typeutil"
+
+	"github.com/motemen/go-template-statictools/templatetypes"
+)
+
+type server struct {
+	conn *conn
+
+	// checker holds the configuration and the packages loaded, shared by the documents
+	checker *templatetypes.Checker
+
+	docs     map[string]*document
+	shutdown bool
+}
+
+// document is a template file opened by the client.
+type document struct {
+	path string
+	text string // the current text, which may not be saved yet
+
+	// results of the last check, of the text saved
+	checked string
+	checker *templatetypes.Checker
+	info    *templatetypes.Info
+}
+
+// span is the range of a node in the document.
+type span struct {
+	node       parse.Node
+	start, end int
+}
+
+func newServer(r io.Reader, w io.Writer, checker *templatetypes.Checker) *server {
+	return &server{
+		conn:    newConn(r, w),
+		checker: checker,
+		docs:    map[string]*document{},
+	}
+}
+
+var errExitWithoutShutdown = errors.New("exit without shutdown")
+
+// serve handles messages until the client exits.
+func (s *server) serve() error {
+	for {
+		msg, err := s.conn.read()
+		if err != nil {
+			return err
+		}
+
+		if msg.Method == "exit" {
+			if !s.shutdown {
+				return errExitWithoutShutdown
+			}
+			return nil
+		}
+
+		result, rerr := s.handle(msg)
+		if msg.ID == nil {
+			// notification
+			if rerr != nil {
+				log.Printf("%s: %s", msg.Method, rerr.Message)
+			}
+			continue
+		}
+
+		resp := &message{ID: msg.ID, Error: rerr}
+		if rerr == nil {
+			b, err := json.Marshal(result)
+			if err != nil {
+				return err
+			}
+			resp.Result = json.RawMessage(b)
+		}
+		if err := s.conn.write(resp); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *server) handle(msg *message) (any, *responseError) {
+	switch msg.Method {
+	case "initialize":
+		return map[string]any{
+			"capabilities": map[string]any{
+				"textDocumentSync": map[string]any{
+					"openClose": true,
+					"change":    1, // full
+					"save":      map[string]any{"includeText": false},
+				},
+				"hoverProvider":      true,
+				"definitionProvider": true,
+				"completionProvider": map[string]any{"triggerCharacters": []string{"."}},
+			},
+			"serverInfo": map[string]any{"name": "gotmpl-lsp"},
+		}, nil
+
+	case "initialized":
+		return nil, nil
+
+	case "shutdown":
+		s.shutdown = true
+		return nil, nil
+
+	case "textDocument/didOpen":
+		var params DidOpenTextDocumentParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, &responseError{Code: codeParseError, Message: err.Error()}
+		}
+		path, err := uriToPath(params.TextDocument.URI)
+		if err != nil {
+			return nil, &responseError{Code: codeInvalidParams, Message: err.Error()}
+		}
+		doc := &document{path: path, text: params.TextDocument.Text}
+		s.docs[params.TextDocument.URI] = doc
+		s.check(params.TextDocument.URI, doc)
+		return nil, nil
+
+	case "textDocument/didChange":
+		var params DidChangeTextDocumentParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, &responseError{Code: codeParseError, Message: err.Error()}
+		}
+		if doc := s.docs[params.TextDocument.URI]; doc != nil && len(params.ContentChanges) > 0 {
+			doc.text = params.ContentChanges[len(params.ContentChanges)-1].Text
+		}
+		return nil, nil
+
+	case "textDocument/didSave":
+		var params DidSaveTextDocumentParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, &responseError{Code: codeParseError, Message: err.Error()}
+		}
+		if doc := s.docs[params.TextDocument.URI]; doc != nil {
+			s.check(params.TextDocument.URI, doc)
+		}
+		return nil, nil
+
+	case "textDocument/didClose":
+		var params DidCloseTextDocumentParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, &responseError{Code: codeParseError, Message: err.Error()}
+		}
+		delete(s.docs, params.TextDocument.URI)
+		s.publish(params.TextDocument.URI, nil)
+		return nil, nil
+
+	case "textDocument/hover", "textDocument/definition", "textDocument/completion":
+		var params TextDocumentPositionParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, &responseError{Code: codeParseError, Message: err.Error()}
+		}
+		doc := s.docs[params.TextDocument.URI]
+		if doc == nil || doc.info == nil {
+			return nil, nil
+		}
+		switch msg.Method {
+		case "textDocument/hover":
+			return doc.hover(params.Position), nil
+		case "textDocument/definition":
+			return doc.definition(params.Position), nil
+		default:
+			return doc.completion(params.Position), nil
+		}
+	}
+
+	if msg.ID == nil {
+		// notifications not supported can be ignored
+		return nil, nil
+	}
+	return nil, &responseError{Code: codeMethodNotFound, Message: fmt.Sprintf("method not supported: %s", msg.Method)}
+}
+
+func (s *server) publish(uri string, diags []Diagnostic) {
+	if diags == nil {
+		diags = []Diagnostic{}
+	}
+	if err := s.conn.notify("textDocument/publishDiagnostics", PublishDiagnosticsParams{URI: uri, Diagnostics: diags}); err != nil {
+		log.Printf("publishDiagnostics: %s", err)
+	}
+}
+
+// check checks the document as saved and publishes the diagnostics.
+// The document is checked alone, as the set of templates it is parsed into is not known;
+// templates it invokes from other files are of unknown types.
+func (s *server) check(uri string, doc *document) {
+	// results of the previous check do not apply to the text saved now
+	doc.checked, doc.checker, doc.info = "", nil, nil
+
+	content, err := os.ReadFile(doc.path)
+	if err != nil {
+		log.Printf("check: %s", err)
+		return
+	}
+
+	name := filepath.Base(doc.path)
+	c, err := s.checker.TemplateSetChecker(&templatetypes.TemplateSet{Name: name, Files: []string{doc.path}})
+	if err != nil {
+		s.publish(uri, []Diagnostic{parseErrorDiagnostic(err)})
+		return
+	}
+	c.AllowUndefinedTemplates = true
+
+	info := &templatetypes.Info{}
+	c.Info = info
+	err = c.Check(name)
+
+	doc.checked = string(content)
+	doc.checker = c
+	doc.info = info
+
+	var diags []Diagnostic
+	for _, err := range templatetypes.UnwrapErrors(err) {
+		var tcErr templatetypes.TypeCheckError
+		if !errors.As(err, &tcErr) || tcErr.Node == nil {
+			diags = append(diags, Diagnostic{Severity: severityError, Source: "gotmplcheck", Message: err.Error()})
+			continue
+		}
+		node := tcErr.Node
+		_, offset := c.Position(node)
+		end := offset + len(node.String())
+		if t, ok := node.(*parse.TemplateNode); ok {
+			// positioned at the name
+			end = offset + len(strconv.Quote(t.Name))
+		}
+		if p := strings.IndexByte(doc.checked[offset:], '\n'); p >= 0 && offset+p < end {
+			end = offset + p
+		}
+		diags = append(diags, Diagnostic{
+			Range:    Range{Start: offsetToPosition(doc.checked, offset), End: offsetToPosition(doc.checked, end)},
+			Severity: severityError,
+			Source:   "gotmplcheck",
+			Message:  tcErr.Message,
+		})
+	}
+	s.publish(uri, diags)
+}
+
+// eg. "template: page.tmpl:3: unexpected EOF"
+var rxParseErrorLine = regexp.MustCompile(`^template: [^:]*:(\d+):`)
+
+func parseErrorDiagnostic(err error) Diagnostic {
+	var pos Position
+	if m := rxParseErrorLine.FindStringSubmatch(err.Error()); m != nil {
+		line, _ := strconv.Atoi(m[1])
+		pos.Line = line - 1
+	}
+	return Diagnostic{Range: Range{Start: pos, End: pos}, Severity: severityError, Source: "gotmplcheck", Message: err.Error()}
+}
+
+// identAt returns the innermost node at offset with its type, and the object denoted by the identifier at offset if any.
+func (d *document) identAt(offset int) (sp *span, typ types.Type, obj types.Object) {
+	node, typ := d.checker.NodeAt(filepath.Base(d.path), offset)
+	if node == nil {
+		return nil, nil, nil
+	}
+	_, start := d.checker.Position(node)
+	sp = &span{node: node, start: start, end: start + len(node.String())}
+
+	var idents []string
+	cursor := sp.start
+	switch node := sp.node.(type) {
+	case *parse.FieldNode:
+		idents = node.Ident
+	case *parse.VariableNode:
+		// $x.Field
+		idents = node.Ident[1:]
+		cursor += len(node.Ident[0])
+	default:
+		return sp, typ, nil
+	}
+
+	objs := d.info.Objects[sp.node]
+	skip := len(objs) - len(idents)
+	for i, ident := range idents {
+		start := cursor + 1 // "."
+		end := start + len(ident)
+		if start <= offset && offset < end && skip+i >= 0 && skip+i < len(objs) {
+			obj = objs[skip+i]
+			if obj == nil && i < len(idents)-1 {
+				typ = nil
+			}
+			return &span{node: sp.node, start: start, end: end}, typ, obj
+		}
+		cursor = end
+	}
+
+	return sp, typ, nil
+}
+
+// unchanged reports whether the current text is the same as the text checked up to end,
+// so that the offsets before end are the same in both.
+func (d *document) unchanged(end int) bool {
+	return end <= len(d.checked) && end <= len(d.text) && d.checked[:end] == d.text[:end]
+}
+
+func qualifier(pkg *types.Package) string {
+	return pkg.Name()
+}
+
+func (d *document) hover(pos Position) *Hover {
+	sp, typ, obj := d.identAt(positionToOffset(d.text, pos))
+	if sp == nil || !d.unchanged(sp.end) {
+		return nil
+	}
+
+	var value string
+	if obj != nil {
+		value = types.ObjectString(obj, qualifier)
+	} else if typ != nil {
+		value = types.TypeString(typ, qualifier)
+	} else {
+		return nil
+	}
+
+	return &Hover{
+		Contents: MarkupContent{Kind: "markdown", Value: "```go\n" + value + "\n```"},
+		Range:    &Range{Start: offsetToPosition(d.text, sp.start), End: offsetToPosition(d.text, sp.end)},
+	}
+}
+
+func (d *document) definition(pos Position) []Location {
+	sp, _, obj := d.identAt(positionToOffset(d.text, pos))
+	if sp == nil || !d.unchanged(sp.end) || obj == nil || !obj.Pos().IsValid() {
+		return nil
+	}
+
+	posn := d.checker.FileSet().Position(obj.Pos())
+	if !posn.IsValid() {
+		return nil
+	}
+	p := Position{Line: posn.Line - 1, Character: posn.Column - 1}
+	return []Location{{URI: pathToURI(posn.Filename), Range: Range{Start: p, End: p}}}
+}
+
+// eg. ".Meta.Ti" in "{{if .Meta.Ti"
+var rxCompletionFields = regexp.MustCompile(`(?:^|[\s(|,-])((?:\.\w*)+)$`)
+
+// completion completes the fields and the methods of the dot type at pos.
+// Variables are not supported.
+// The action being typed at pos may not be saved yet, so the dot type is looked up at the start of the action
+// in the text checked, which must be the same as the current text up to there.
+func (d *document) completion(pos Position) []CompletionItem {
+	offset := positionToOffset(d.text, pos)
+	before := d.text[:offset]
+
+	start := strings.LastIndex(before, "{{")
+	if start < 0 || strings.Contains(before[start:], "}}") {
+		return nil
+	}
+	m := rxCompletionFields.FindStringSubmatch(before[start+2:])
+	if m == nil {
+		return nil
+	}
+
+	if !d.unchanged(start) {
+		// offsets in the text checked do not correspond to the current text
+		return nil
+	}
+	typ, _ := d.checker.TypeAt(filepath.Base(d.path), start)
+	names := strings.Split(m[1][1:], ".")
+	for _, name := range names[:len(names)-1] {
+		if typ == nil {
+			return nil
+		}
+		typ = memberType(typ, name)
+	}
+	if typ == nil {
+		return nil
+	}
+
+	items := []CompletionItem{}
+	for _, item := range members(typ) {
+		if strings.HasPrefix(item.Label, names[len(names)-1]) {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// memberType returns the type of the field or the method result name of typ.
+func memberType(typ types.Type, name string) types.Type {
+	obj, _, _ := types.LookupFieldOrMethod(typ, true, nil, name)
+	switch obj := obj.(type) {
+	case *types.Var:
+		return obj.Type()
+	case *types.Func:
+		if results := obj.Type().(*types.Signature).Results(); results.Len() > 0 {
+			return results.At(0).Type()
+		}
+		return nil
+	}
+
+	if m, ok := deref(typ).Underlying().(*types.Map); ok {
+		return m.Elem()
+	}
+	return nil
+}
+
+func deref(typ types.Type) types.Type {
+	if ptr, ok := typ.Underlying().(*types.Pointer); ok {
+		return ptr.Elem()
+	}
+	return typ
+}
+
+// members returns the exported fields, including promoted ones, and methods of typ.
+func members(typ types.Type) []CompletionItem {
+	var items []CompletionItem
+	seen := map[string]bool{}
+
+	for _, sel := range typeutil.IntuitiveMethodSet(typ, nil) {
+		obj := sel.Obj()
+		if obj.Exported() && !seen[obj.Name()] {
+			seen[obj.Name()] = true
+			items = append(items, CompletionItem{Label: obj.Name(), Kind: completionKindMethod, Detail: types.TypeString(obj.Type(), qualifier)})
+		}
+	}
+
+	var addFields func(typ types.Type, depth int)
+	addFields = func(typ types.Type, depth int) {
+		st, ok := deref(typ).Underlying().(*types.Struct)
+		if !ok || depth > 8 {
+			return
+		}
+		for i := 0; i < st.NumFields(); i++ {
+			f := st.Field(i)
+			if f.Exported() && !seen[f.Name()] {
+				seen[f.Name()] = true
+				items = append(items, CompletionItem{Label: f.Name(), Kind: completionKindField, Detail: types.TypeString(f.Type(), qualifier)})
+			}
+			if f.Embedded() {
+				addFields(f.Type(), depth+1)
+			}
+		}
+	}
+	addFields(typ, 0)
+
+	sort.Slice(items, func(i, j int) bool { return items[i].Label < items[j].Label })
+	return items
+}
+
+// eg. "/C:/path" of "file:///C:/path"
+var rxDrivePath = regexp.MustCompile(`^/[A-Za-z]:`)
+
+func uriToPath(uri string) (string, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return "", err
+	}
+	if u.Scheme != "file" {
+		return "", fmt.Errorf("unsupported URI: %s", uri)
+	}
+	path := u.Path
+	if rxDrivePath.MatchString(path) {
+		path = path[1:]
+	}
+	return filepath.FromSlash(path), nil
+}
+
+func pathToURI(path string) string {
+	path = filepath.ToSlash(path)
+	if !strings.HasPrefix(path, "/") {
+		// "C:/path" on Windows
+		path = "/" + path
+	}
+	return (&url.URL{Scheme: "file", Path: path}).String()
+}
+
+// offsetToPosition converts a byte offset in text to an LSP position.
+func offsetToPosition(text string, offset int) Position {
+	if offset > len(text) {
+		offset = len(text)
+	}
+	lineStart := strings.LastIndexByte(text[:offset], '\n') + 1
+	character := 0
+	for _, r := range text[lineStart:offset] {
+		character += utf16Len(r)
+	}
+	return Position{Line: strings.Count(text[:offset], "\n"), Character: character}
+}
+
+// positionToOffset converts an LSP position to a byte offset in text.
+func positionToOffset(text string, pos Position) int {
+	offset := 0
+	for i := 0; i < pos.Line; i++ {
+		p := strings.IndexByte(text[offset:], '\n')
+		if p < 0 {
+			return len(text)
+		}
+		offset += p + 1
+	}
+
+	character := 0
+	for i, r := range text[offset:] {
+		if character >= pos.Character || r == '\n' {
+			return offset + i
+		}
+		character += utf16Len(r)
+	}
+	return len(text)
+}
+
+func utf16Len(r rune) int {
+	if r >= 0x10000 {
+		return 2
+	}
+	return 1
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gotest.tools/v3/assert"
+
+	"github.com/motemen/go-template-statictools/templatetypes"
+)
+
+type client struct {
+	t        *testing.T
+	conn     *conn
+	messages chan *message
+	nextID   int
+
+	diagnostics map[string][]Diagnostic
+}
+
+func (c *client) notify(method string, params any) {
+	assert.NilError(c.t, c.conn.notify(method, params))
+}
+
+// call sends a request and waits for its response, collecting diagnostics published meanwhile.
+func (c *client) call(method string, params any, result any) {
+	c.nextID++
+	id := json.RawMessage(fmt.Sprint(c.nextID))
+	p, err := json.Marshal(params)
+	assert.NilError(c.t, err)
+	assert.NilError(c.t, c.conn.write(&message{ID: &id, Method: method, Params: p}))
+
+	for msg := range c.messages {
+		if msg.Method == "textDocument/publishDiagnostics" {
+			var params PublishDiagnosticsParams
+			assert.NilError(c.t, json.Unmarshal(msg.Params, &params))
+			c.diagnostics[params.URI] = params.Diagnostics
+			continue
+		}
+
+		assert.Equal(c.t, string(*msg.ID), string(id))
+		assert.Assert(c.t, msg.Error == nil, "%s: %v", method, msg.Error)
+		b, err := json.Marshal(msg.Result)
+		assert.NilError(c.t, err)
+		assert.NilError(c.t, json.Unmarshal(b, result))
+		return
+	}
+}
+
+// receive reads messages from the server in background, as the server blocks on writing notifications.
+func (c *client) receive() {
+	defer close(c.messages)
+	for {
+		msg, err := c.conn.read()
+		if err != nil {
+			return
+		}
+		c.messages <- msg
+	}
+}
+
+func TestServer(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "page.tmpl")
+	text := `{{/* @type github.com/motemen/go-template-statictools/example.Data */}}
+{{.Meta.Title}}
+{{.Meta.Nope}}
+{{range .Items}}{{.Name}}{{end}}
+`
+	assert.NilError(t, os.WriteFile(path, []byte(text), 0o644))
+	uri := pathToURI(path)
+
+	// another page in the directory, which is not parsed into the set of page.tmpl
+	other := `{{define "page.tmpl"}}{{.}}{{end}}`
+	assert.NilError(t, os.WriteFile(filepath.Join(dir, "other.tmpl"), []byte(other), 0o644))
+
+	serverIn, clientOut := io.Pipe()
+	clientIn, serverOut := io.Pipe()
+
+	done := make(chan error)
+	go func() {
+		done <- newServer(serverIn, serverOut, &templatetypes.Checker{}).serve()
+	}()
+
+	c := &client{t: t, conn: newConn(clientIn, clientOut), messages: make(chan *message, 16), diagnostics: map[string][]Diagnostic{}}
+	go c.receive()
+
+	var initResult struct {
+		Capabilities map[string]any `json:"capabilities"`
+	}
+	c.call("initialize", map[string]any{}, &initResult)
+	assert.Equal(t, initResult.Capabilities["hoverProvider"], true)
+	c.notify("initialized", map[string]any{})
+
+	c.notify("textDocument/didOpen", DidOpenTextDocumentParams{TextDocument: TextDocumentItem{URI: uri, Text: text}})
+
+	t.Run("diagnostics", func(t *testing.T) {
+		// responses are sent in order, so diagnostics are received by then
+		var hover *Hover
+		c.call("textDocument/hover", TextDocumentPositionParams{TextDocument: TextDocumentIdentifier{URI: uri}}, &hover)
+
+		diags := c.diagnostics[uri]
+		assert.Equal(t, len(diags), 1)
+		assert.DeepEqual(t, diags[0].Range, Range{Start: Position{Line: 2, Character: 2}, End: Position{Line: 2, Character: 12}})
+		assert.Equal(t, diags[0].Message, "can't evaluate field Nope in type github.com/motemen/go-template-statictools/example.Meta")
+	})
+
+	t.Run("hover", func(t *testing.T) {
+		var hover *Hover
+		c.call("textDocument/hover", TextDocumentPositionParams{
+			TextDocument: TextDocumentIdentifier{URI: uri},
+			Position:     Position{Line: 1, Character: 9}, // Title
+		}, &hover)
+		assert.Assert(t, hover != nil)
+		assert.Equal(t, hover.Contents.Value, "```go\nfield Title string\n```")
+		assert.DeepEqual(t, hover.Range, &Range{Start: Position{Line: 1, Character: 8}, End: Position{Line: 1, Character: 13}})
+
+		c.call("textDocument/hover", TextDocumentPositionParams{
+			TextDocument: TextDocumentIdentifier{URI: uri},
+			Position:     Position{Line: 3, Character: 9}, // .Items
+		}, &hover)
+		assert.Assert(t, hover != nil)
+		assert.Equal(t, hover.Contents.Value, "```go\nfield Items []example.Item\n```")
+	})
+
+	t.Run("definition", func(t *testing.T) {
+		var locs []Location
+		c.call("textDocument/definition", TextDocumentPositionParams{
+			TextDocument: TextDocumentIdentifier{URI: uri},
+			Position:     Position{Line: 3, Character: 20}, // .Name
+		}, &locs)
+		assert.Equal(t, len(locs), 1)
+		assert.Assert(t, strings.HasSuffix(locs[0].URI, "/example/type.go"), locs[0].URI)
+		assert.Equal(t, locs[0].Range.Start.Line, 12)
+	})
+
+	t.Run("hover on text changed", func(t *testing.T) {
+		change := func(text string) {
+			c.notify("textDocument/didChange", DidChangeTextDocumentParams{
+				TextDocument: TextDocumentIdentifier{URI: uri},
+				ContentChanges: []struct {
+					Text string `json:"text"`
+				}{{Text: text}},
+			})
+		}
+		position := TextDocumentPositionParams{
+			TextDocument: TextDocumentIdentifier{URI: uri},
+			Position:     Position{Line: 1, Character: 9}, // Title
+		}
+
+		var hover *Hover
+		change(text + "{{.Items}}")
+		c.call("textDocument/hover", position, &hover)
+		assert.Assert(t, hover != nil)
+		assert.Equal(t, hover.Contents.Value, "```go\nfield Title string\n```")
+
+		// types of the text checked are not used for the text changed before the position
+		change("{{.Items}}\n" + text)
+		c.call("textDocument/hover", position, &hover)
+		assert.Assert(t, hover == nil)
+	})
+
+	t.Run("completion", func(t *testing.T) {
+		labels := func(items []CompletionItem) []string {
+			var labels []string
+			for _, item := range items {
+				labels = append(labels, item.Label)
+			}
+			return labels
+		}
+
+		changed := text + "{{.Meta.T"
+		c.notify("textDocument/didChange", DidChangeTextDocumentParams{
+			TextDocument: TextDocumentIdentifier{URI: uri},
+			ContentChanges: []struct {
+				Text string `json:"text"`
+			}{{Text: changed}},
+		})
+
+		var items []CompletionItem
+		c.call("textDocument/completion", TextDocumentPositionParams{
+			TextDocument: TextDocumentIdentifier{URI: uri},
+			Position:     Position{Line: 4, Character: 9},
+		}, &items)
+		assert.DeepEqual(t, labels(items), []string{"Title"})
+
+		c.call("textDocument/completion", TextDocumentPositionParams{
+			TextDocument: TextDocumentIdentifier{URI: uri},
+			Position:     Position{Line: 3, Character: 19}, // {{range .Items}}{{.
+		}, &items)
+		assert.DeepEqual(t, labels(items), []string{"Field", "Method", "Name"})
+
+		// types of the text checked are not used for the text changed before the action
+		c.notify("textDocument/didChange", DidChangeTextDocumentParams{
+			TextDocument: TextDocumentIdentifier{URI: uri},
+			ContentChanges: []struct {
+				Text string `json:"text"`
+			}{{Text: "{{.Items}}\n" + changed}},
+		})
+		c.call("textDocument/completion", TextDocumentPositionParams{
+			TextDocument: TextDocumentIdentifier{URI: uri},
+			Position:     Position{Line: 5, Character: 9},
+		}, &items)
+		assert.Equal(t, len(items), 0)
+	})
+
+	var result any
+	c.call("shutdown", nil, &result)
+	c.notify("exit", nil)
+	assert.NilError(t, <-done)
+}
+
+func TestURIToPath(t *testing.T) {
+	tests := []struct {
+		uri  string
+		path string
+	}{
+		{"file:///home/user/page.tmpl", "/home/user/page.tmpl"},
+		{"file:///home/user/my%20page.tmpl", "/home/user/my page.tmpl"},
+		{"file:///C:/Users/user/page.tmpl", filepath.FromSlash("C:/Users/user/page.tmpl")},
+		{"file:///c%3A/Users/user/page.tmpl", filepath.FromSlash("c:/Users/user/page.tmpl")},
+	}
+	for _, test := range tests {
+		path, err := uriToPath(test.uri)
+		assert.NilError(t, err)
+		assert.Equal(t, path, test.path, test.uri)
+		assert.Equal(t, pathToURI(path), strings.Replace(test.uri, "%3A", ":", 1), test.uri)
+	}
+
+	_, err := uriToPath("untitled:page.tmpl")
+	assert.ErrorContains(t, err, "unsupported URI")
+}
@@ -1,9 +1,12 @@
 package main
 
 import (
+	"errors"
 	"flag"
+	"fmt"
 	"log"
 	"os"
+	"strings"
 
 	"github.com/motemen/go-template-statictools/templatetypes"
 )
@@ -12,26 +15,28 @@ func main() {
 	var (
 		flagDot     = flag.String("dot", "", "`path/to/pkg.type` of template data")
 		flagVerbose = flag.Bool("verbose", false, "enable verbose logging")
-		flagFuncMap = flag.String("funcmap", "", "`path/to/pkg.name` of template FuncMap")
+		flagFuncMap funcMapFlag
 		flagSoft    = flag.Bool("soft", false, "allow undefined functions or templates")
+		flagHTML    = flag.Bool("html", false, "check templates as html/template")
+		flagBind    = flag.String("bindings", "", "comma-separated Go package `patterns` to find Execute/ExecuteTemplate calls in, to check templates with the types of data passed")
+		flagCatalog = flag.String("catalog", "", "comma-separated `names` of function catalogs to enable (available: "+strings.Join(templatetypes.Catalogs(), ", ")+")")
+		flagSets    = flag.String("sets", "", "JSON configuration `file` of template sets to check independently")
 	)
 
+	flag.Var(&flagFuncMap, "funcmap", "`path/to/pkg.name` of template FuncMap (can be repeated or comma-separated; later ones override earlier ones)")
 	flag.Parse()
 
 	log.SetFlags(0)
 
 	args := flag.Args()
-	if len(args) == 0 {
+	if len(args) == 0 && *flagSets == "" {
 		usageAndExit()
 	}
+	if len(args) > 0 && *flagSets != "" {
+		log.Fatalf("-sets does not take files or packages: %s", strings.Join(args, " "))
+	}
 
 	var checker templatetypes.Checker
-	for _, arg := range args {
-		err := checker.ParseFile(arg)
-		if err != nil {
-			panic(err)
-		}
-	}
 
 	if flagDot != nil {
 		checker.DotType = *flagDot
@@ -39,27 +44,174 @@ func main() {
 	if flagVerbose != nil {
 		checker.Verbose = *flagVerbose
 	}
-	if flagFuncMap != nil {
-		checker.FuncMapVar = *flagFuncMap
+	checker.FuncMapVar = flagFuncMap.String()
+	if flagHTML != nil {
+		checker.HTML = *flagHTML
+	}
+	if flagCatalog != nil && *flagCatalog != "" {
+		checker.Catalogs = strings.Split(*flagCatalog, ",")
 	}
 	if flagSoft != nil && *flagSoft {
 		checker.AllowUndefinedFuncs = true
 		checker.AllowUndefinedTemplates = true
 	}
 
+	if *flagSets != "" {
+		sets, err := loadSetsConfig(*flagSets)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if !checkSets(&checker, sets) {
+			os.Exit(1)
+		}
+		return
+	}
+
+	if isPackagePatterns(args) {
+		if !checkPackages(&checker, args) {
+			os.Exit(1)
+		}
+		return
+	}
+
+	for _, arg := range args {
+		err := checker.ParseFile(arg)
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	if flagBind != nil && *flagBind != "" {
+		if !checkBindings(&checker, strings.Split(*flagBind, ","), args[0]) {
+			os.Exit(1)
+		}
+		return
+	}
+
 	err := checker.Check(args[0])
 	if err != nil {
-		if u, ok := err.(interface{ Unwrap() []error }); ok {
-			for _, err := range u.Unwrap() {
-				log.Println(checker.FormatError(err))
-			}
-		} else {
-			log.Println(checker.FormatError(err))
-		}
+		report(&checker, err)
 		os.Exit(1)
 	}
 }
 
+// checkBindings checks the templates executed in the packages of patterns.
+// Templates executed by Execute are assumed to be entryPoint.
+func checkBindings(checker *templatetypes.Checker, patterns []string, entryPoint string) bool {
+	bindings, err := checker.FindBindings(patterns...)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(bindings) == 0 {
+		log.Fatalf("no Execute/ExecuteTemplate calls found in %s", strings.Join(patterns, ", "))
+	}
+
+	ok := true
+	checked := map[string]bool{}
+	for _, b := range bindings {
+		if b.Template == "" {
+			b.Template = entryPoint
+		}
+
+		key := fmt.Sprintf("%s\x00%v\x00%v", b.Template, b.Dot, b.HTML)
+		if checked[key] {
+			continue
+		}
+		checked[key] = true
+
+		if checker.Verbose {
+			log.Printf("%s: checking %s with %v", b.Pos, b.Template, b.Dot)
+		}
+		if err := checker.CheckBinding(b); err != nil {
+			report(checker, err)
+			ok = false
+		}
+	}
+
+	return ok
+}
+
+// isPackagePatterns reports whether args are Go package patterns rather than template files,
+// ie. directories or patterns containing "...".
+func isPackagePatterns(args []string) bool {
+	for _, arg := range args {
+		if strings.Contains(arg, "...") {
+			continue
+		}
+		if fi, err := os.Stat(arg); err == nil && fi.IsDir() {
+			continue
+		}
+		return false
+	}
+	return true
+}
+
+// checkPackages checks the template sets built in the packages of patterns,
+// with the types of data passed to Execute/ExecuteTemplate calls on them.
+// Files of template sets never executed statically are checked with unknown data types.
+func checkPackages(checker *templatetypes.Checker, patterns []string) bool {
+	ok := true
+
+	sets, err := checker.FindTemplateSets(patterns...)
+	if err != nil {
+		var unresolved *templatetypes.UnresolvedError
+		if !errors.As(err, &unresolved) {
+			log.Fatal(err)
+		}
+		// templates that cannot be resolved are skipped
+		for _, err := range unresolved.Errors {
+			log.Printf("warning: %s", err)
+		}
+	}
+
+	bindings, err := checker.FindBindings(patterns...)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	for i := range sets {
+		c, err := checker.CheckTemplateSet(&sets[i], bindings)
+		if c == nil {
+			log.Printf("%s: %s", sets[i].Pos, err)
+			ok = false
+		} else if err != nil {
+			report(c, err)
+			ok = false
+		}
+	}
+
+	return ok
+}
+
+// reported holds the messages reported by each Checker, as templates shared by multiple entry points are checked multiple times.
+// Template sets are checked by Checkers of their own, and the same message is reported for each set it is found in.
+var reported = map[*templatetypes.Checker]map[string]bool{}
+
+func report(checker *templatetypes.Checker, err error) {
+	if reported[checker] == nil {
+		reported[checker] = map[string]bool{}
+	}
+	for _, err := range templatetypes.UnwrapErrors(err) {
+		msg := checker.FormatError(err)
+		if !reported[checker][msg] {
+			reported[checker][msg] = true
+			log.Println(msg)
+		}
+	}
+}
+
+// funcMapFlag collects -funcmap values.
+type funcMapFlag []string
+
+func (f *funcMapFlag) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *funcMapFlag) Set(value string) error {
+	*f = append(*f, value)
+	return nil
+}
+
 func usageAndExit() {
 	log.Printf("Usage: %s <file> ...", os.Args[0])
 	flag.PrintDefaults()
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/motemen/go-template-statictools/templatetypes"
+)
+
+// setsConfig is the configuration given by -sets, which groups template files into sets checked independently,
+// as applications build a *template.Template for each page. eg.
+//
+//	{
+//	  "sets": [
+//	    {"name": "home", "files": ["layout.tmpl", "home.tmpl"], "dot": "example.com/app.HomePage"},
+//	    {"name": "user", "files": ["layout.tmpl", "user/*.tmpl"], "dot": "example.com/app.UserPage", "funcmap": ["example.com/app.userFuncs"]}
+//	  ]
+//	}
+type setsConfig struct {
+	Sets []setConfig `json:"sets"`
+}
+
+type setConfig struct {
+	Name string `json:"name"`
+
+	// paths or glob patterns of the files, relative to the configuration file
+	Files []string `json:"files"`
+
+	// names of the templates to check, defaults to the first file as Template.ParseFiles names the template
+	EntryPoints []string `json:"entryPoints"`
+
+	// type of data, overriding -dot
+	Dot string `json:"dot"`
+
+	// FuncMap variables, added to -funcmap
+	FuncMap []string `json:"funcmap"`
+
+	HTML       bool   `json:"html"`
+	LeftDelim  string `json:"leftDelim"`
+	RightDelim string `json:"rightDelim"`
+}
+
+// loadSetsConfig reads the configuration file at path and returns the template sets in it.
+func loadSetsConfig(path string) ([]templatetypes.TemplateSet, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var config setsConfig
+	if err := json.Unmarshal(content, &config); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	var (
+		sets  []templatetypes.TemplateSet
+		names = map[string]bool{}
+		dir   = filepath.Dir(path)
+	)
+	for i, c := range config.Sets {
+		if c.Name == "" {
+			return nil, fmt.Errorf("%s: set #%d: name is required", path, i+1)
+		}
+		if names[c.Name] {
+			return nil, fmt.Errorf("%s: set %q: duplicate name", path, c.Name)
+		}
+		names[c.Name] = true
+
+		set := templatetypes.TemplateSet{
+			Name:        c.Name,
+			EntryPoints: c.EntryPoints,
+			DotType:     c.Dot,
+			FuncMapVar:  strings.Join(c.FuncMap, ","),
+			HTML:        c.HTML,
+			LeftDelim:   c.LeftDelim,
+			RightDelim:  c.RightDelim,
+		}
+		for _, pattern := range c.Files {
+			if !filepath.IsAbs(pattern) {
+				pattern = filepath.Join(dir, pattern)
+			}
+			files, err := filepath.Glob(pattern)
+			if err != nil {
+				return nil, fmt.Errorf("%s: set %q: %w", path, c.Name, err)
+			}
+			if len(files) == 0 {
+				return nil, fmt.Errorf("%s: set %q: no files match %s", path, c.Name, pattern)
+			}
+			set.Files = append(set.Files, files...)
+		}
+		if len(set.Files) == 0 {
+			return nil, fmt.Errorf("%s: set %q: files are required", path, c.Name)
+		}
+		if len(set.EntryPoints) == 0 {
+			set.EntryPoints = []string{filepath.Base(set.Files[0])}
+		}
+
+		sets = append(sets, set)
+	}
+
+	return sets, nil
+}
+
+// checkSets checks each of the template sets configured independently, sharing the packages loaded.
+func checkSets(checker *templatetypes.Checker, sets []templatetypes.TemplateSet) bool {
+	if err := checker.LoadPackages(sets); err != nil {
+		log.Fatal(err)
+	}
+
+	ok := true
+	for i := range sets {
+		if checker.Verbose {
+			log.Printf("checking set %s", sets[i].Name)
+		}
+		c, err := checker.CheckTemplateSet(&sets[i], nil)
+		if c == nil {
+			log.Printf("set %s: %s", sets[i].Name, err)
+			ok = false
+		} else if err != nil {
+			report(c, err)
+			ok = false
+		}
+	}
+
+	return ok
+}
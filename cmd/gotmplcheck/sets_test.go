@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gotest.tools/v3/assert"
+
+	"github.com/motemen/go-template-statictools/templatetypes"
+)
+
+func TestLoadSetsConfig(t *testing.T) {
+	dir := t.TempDir()
+	for _, file := range []string{"layout.tmpl", "home.tmpl", "user/profile.tmpl", "user/settings.tmpl"} {
+		path := filepath.Join(dir, file)
+		assert.NilError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		assert.NilError(t, os.WriteFile(path, []byte(`{{.}}`), 0o644))
+	}
+	path := func(file string) string {
+		return filepath.Join(dir, file)
+	}
+
+	tests := []struct {
+		name   string
+		config string
+		sets   []templatetypes.TemplateSet
+		err    string
+	}{
+		{
+			"files relative to the config",
+			`{"sets": [{"name": "home", "files": ["layout.tmpl", "home.tmpl"], "dot": "example.com/app.HomePage"}]}`,
+			[]templatetypes.TemplateSet{
+				{Name: "home", Files: []string{path("layout.tmpl"), path("home.tmpl")}, EntryPoints: []string{"layout.tmpl"}, DotType: "example.com/app.HomePage"},
+			},
+			"",
+		},
+		{
+			"globs and options",
+			`{"sets": [{"name": "user", "files": ["layout.tmpl", "user/*.tmpl"], "entryPoints": ["profile.tmpl"], "funcmap": ["example.com/app.funcs", "example.com/app.userFuncs"], "html": true, "leftDelim": "[[", "rightDelim": "]]"}]}`,
+			[]templatetypes.TemplateSet{
+				{
+					Name:        "user",
+					Files:       []string{path("layout.tmpl"), path("user/profile.tmpl"), path("user/settings.tmpl")},
+					EntryPoints: []string{"profile.tmpl"},
+					FuncMapVar:  "example.com/app.funcs,example.com/app.userFuncs",
+					HTML:        true,
+					LeftDelim:   "[[",
+					RightDelim:  "]]",
+				},
+			},
+			"",
+		},
+		{
+			"multiple sets",
+			`{"sets": [{"name": "home", "files": ["home.tmpl"]}, {"name": "user", "files": ["user/profile.tmpl"]}]}`,
+			[]templatetypes.TemplateSet{
+				{Name: "home", Files: []string{path("home.tmpl")}, EntryPoints: []string{"home.tmpl"}},
+				{Name: "user", Files: []string{path("user/profile.tmpl")}, EntryPoints: []string{"profile.tmpl"}},
+			},
+			"",
+		},
+		{
+			"duplicate names",
+			`{"sets": [{"name": "home", "files": ["home.tmpl"]}, {"name": "home", "files": ["layout.tmpl"]}]}`,
+			nil,
+			`set "home": duplicate name`,
+		},
+		{
+			"no name",
+			`{"sets": [{"files": ["home.tmpl"]}]}`,
+			nil,
+			"set #1: name is required",
+		},
+		{
+			"no files",
+			`{"sets": [{"name": "home"}]}`,
+			nil,
+			`set "home": files are required`,
+		},
+		{
+			"no files matching",
+			`{"sets": [{"name": "home", "files": ["pages/*.tmpl"]}]}`,
+			nil,
+			`set "home": no files match ` + path("pages/*.tmpl"),
+		},
+		{
+			"invalid JSON",
+			`{"sets": [`,
+			nil,
+			"unexpected end of JSON input",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := filepath.Join(dir, "sets.json")
+			assert.NilError(t, os.WriteFile(config, []byte(test.config), 0o644))
+
+			sets, err := loadSetsConfig(config)
+			if test.err != "" {
+				assert.ErrorContains(t, err, test.err)
+				return
+			}
+			assert.NilError(t, err)
+			assert.DeepEqual(t, exportedFields(sets), exportedFields(test.sets))
+		})
+	}
+}
+
+// setFields is the fields of TemplateSet configured, to be compared without the unexported ones.
+type setFields struct {
+	Name                  string
+	Files, EntryPoints    []string
+	DotType, FuncMapVar   string
+	HTML                  bool
+	LeftDelim, RightDelim string
+}
+
+func exportedFields(sets []templatetypes.TemplateSet) []setFields {
+	fields := make([]setFields, len(sets))
+	for i, set := range sets {
+		fields[i] = setFields{set.Name, set.Files, set.EntryPoints, set.DotType, set.FuncMapVar, set.HTML, set.LeftDelim, set.RightDelim}
+	}
+	return fields
+}
+
+func TestCheckSets(t *testing.T) {
+	dir := t.TempDir()
+	for file, content := range map[string]string{
+		"layout.tmpl":  `{{len 1}}{{template "content" .}}`,
+		"home.tmpl":    `{{define "content"}}{{end}}`,
+		"profile.tmpl": `{{define "content"}}{{end}}`,
+	} {
+		assert.NilError(t, os.WriteFile(filepath.Join(dir, file), []byte(content), 0o644))
+	}
+	config := filepath.Join(dir, "sets.json")
+	assert.NilError(t, os.WriteFile(config, []byte(`{"sets": [{"name": "home", "files": ["layout.tmpl", "home.tmpl"]}, {"name": "user", "files": ["layout.tmpl", "profile.tmpl"]}]}`), 0o644))
+
+	sets, err := loadSetsConfig(config)
+	assert.NilError(t, err)
+
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+
+	assert.Assert(t, !checkSets(&templatetypes.Checker{}, sets))
+	// the error in the file shared is reported for each set
+	assert.Equal(t, strings.Count(buf.String(), "invalid argument type"), 2, buf.String())
+}
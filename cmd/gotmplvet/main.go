@@ -0,0 +1,12 @@
+// gotmplvet runs the gotmplcheck analyzer, as a standalone command or by go vet -vettool.
+package main
+
+import (
+	"golang.org/x/tools/go/analysis/singlechecker"
+
+	"github.com/motemen/go-template-statictools/analyzer"
+)
+
+func main() {
+	singlechecker.Main(analyzer.Analyzer)
+}
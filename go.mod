@@ -3,12 +3,13 @@ module github.com/motemen/go-template-statictools
 go 1.20
 
 require (
-	github.com/google/go-cmp v0.5.9 // indirect
-	golang.org/x/mod v0.12.0 // indirect
+	github.com/google/go-cmp v0.6.0 // indirect
+	golang.org/x/mod v0.18.0 // indirect
+	golang.org/x/sync v0.7.0 // indirect
 )
 
 require (
-	golang.org/x/sys v0.10.0 // indirect
-	golang.org/x/tools v0.11.1
+	golang.org/x/sys v0.21.0 // indirect
+	golang.org/x/tools v0.22.0
 	gotest.tools/v3 v3.5.0
 )
@@ -0,0 +1,222 @@
+package templatetypes
+
+import (
+	"fmt"
+	"go/ast"
+	"go/constant"
+	"go/token"
+	"go/types"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// Binding is a pair of a template and the type of the data it is executed with,
+// found at a call of Template.Execute or Template.ExecuteTemplate.
+type Binding struct {
+	// name of the template executed, or empty if it cannot be determined statically
+	Template string
+
+	// static type of the data argument, or nil if unknown (eg. empty interface)
+	Dot types.Type
+
+	// HTML is true if the template is an html/template one
+	HTML bool
+
+	// template set of the receiver, or nil if it cannot be determined statically
+	Set *TemplateSet
+
+	// position of the call
+	Pos token.Position
+}
+
+// FindBindings loads the Go packages matching patterns and returns bindings found in them.
+// Calls with template names not being constant strings are ignored.
+func (s *Checker) FindBindings(patterns ...string) ([]Binding, error) {
+	paths, err := s.loadPatterns(patterns)
+	if err != nil {
+		return nil, err
+	}
+
+	var pkgs []*packages.Package
+	for _, path := range paths {
+		pkgs = append(pkgs, s.packages[path]...)
+	}
+	return s.bindings(pkgs), nil
+}
+
+// PackageBindings returns the bindings found in pkg, which is loaded by the caller
+// with packages.NeedName, NeedTypes, NeedTypesInfo and NeedSyntax.
+func (s *Checker) PackageBindings(pkg *packages.Package) []Binding {
+	s.addPackage(pkg)
+	return s.bindings([]*packages.Package{pkg})
+}
+
+func (s *Checker) bindings(pkgs []*packages.Package) []Binding {
+	var (
+		bindings []Binding
+		seen     = map[token.Position]bool{}
+	)
+	for _, pkg := range pkgs {
+		for _, b := range s.findBindings(pkg) {
+			// test variants of packages, loaded with Tests, share the same files
+			if seen[b.Pos] {
+				continue
+			}
+			seen[b.Pos] = true
+			bindings = append(bindings, b)
+		}
+	}
+
+	sort.Slice(bindings, func(i, j int) bool {
+		return positionLess(bindings[i].Pos, bindings[j].Pos)
+	})
+
+	return bindings
+}
+
+func (s *Checker) findBindings(pkg *packages.Package) []Binding {
+	var bindings []Binding
+
+	finder := templateSetFinder{pkg: pkg, visiting: map[types.Object]bool{}}
+
+	for _, file := range pkg.Syntax {
+		ast.Inspect(file, func(node ast.Node) bool {
+			call, ok := node.(*ast.CallExpr)
+			if !ok {
+				return true
+			}
+			sel, ok := call.Fun.(*ast.SelectorExpr)
+			if !ok {
+				return true
+			}
+			selection := pkg.TypesInfo.Selections[sel]
+			if selection == nil || selection.Kind() != types.MethodVal {
+				return true
+			}
+			html, ok := isTemplateType(selection.Recv())
+			if !ok {
+				return true
+			}
+
+			pos := pkg.Fset.Position(call.Pos())
+
+			var b Binding
+			switch sel.Sel.Name {
+			case "Execute":
+				if len(call.Args) != 2 {
+					return true
+				}
+				b.Dot = pkg.TypesInfo.TypeOf(call.Args[1])
+
+			case "ExecuteTemplate":
+				if len(call.Args) != 3 {
+					return true
+				}
+				tv := pkg.TypesInfo.Types[call.Args[1]]
+				if tv.Value == nil || tv.Value.Kind() != constant.String {
+					s.debugf(nil, "%s: skip: template name is not a constant string", pos)
+					return true
+				}
+				b.Template = constant.StringVal(tv.Value)
+				b.Dot = pkg.TypesInfo.TypeOf(call.Args[2])
+
+			default:
+				return true
+			}
+
+			// errors are reported by FindTemplateSets
+			b.Set, _ = finder.eval(sel.X)
+			if b.Template == "" && b.Set != nil {
+				b.Template = b.Set.Name
+			}
+
+			b.Dot = bindingDotType(b.Dot)
+			b.HTML = html
+			b.Pos = pos
+			bindings = append(bindings, b)
+
+			return true
+		})
+	}
+
+	return bindings
+}
+
+// isTemplateType reports whether typ is *Template of text/template or html/template.
+func isTemplateType(typ types.Type) (html bool, ok bool) {
+	if ptr, ok := typ.(*types.Pointer); ok {
+		typ = ptr.Elem()
+	}
+	named, ok := typ.(*types.Named)
+	if !ok || named.Obj().Name() != "Template" || named.Obj().Pkg() == nil {
+		return false, false
+	}
+	switch named.Obj().Pkg().Path() {
+	case "text/template":
+		return false, true
+	case "html/template":
+		return true, true
+	}
+	return false, false
+}
+
+// bindingDotType returns the type of dot for data of typ.
+// text/template looks into the dynamic values of interfaces, so empty interfaces result in unknown types.
+func bindingDotType(typ types.Type) types.Type {
+	if typ == nil {
+		return nil
+	}
+	if basic, ok := typ.(*types.Basic); ok && basic.Kind() == types.UntypedNil {
+		return nil
+	}
+	if iface, ok := typ.Underlying().(*types.Interface); ok && iface.Empty() {
+		return nil
+	}
+	return typ
+}
+
+// CheckBinding checks the template of b with its type of dot.
+// The template is looked up by its name, or by the base name of the files parsed, as Template.ParseFiles names templates.
+func (s *Checker) CheckBinding(b Binding) error {
+	if b.Template == "" {
+		return fmt.Errorf("%s: template name is unknown", b.Pos)
+	}
+
+	entryPoint, err := s.resolveTemplateName(b.Template)
+	if err != nil {
+		return fmt.Errorf("%s: %w", b.Pos, err)
+	}
+
+	html := s.HTML
+	s.HTML = s.HTML || b.HTML
+	defer func() { s.HTML = html }()
+
+	return s.check(entryPoint, &b)
+}
+
+// resolveTemplateName returns the name of the tree for template name.
+func (s *Checker) resolveTemplateName(name string) (string, error) {
+	if _, ok := s.treeSet[name]; ok {
+		return name, nil
+	}
+
+	var candidates []string
+	for treeName := range s.treeSet {
+		if filepath.Base(treeName) == name {
+			candidates = append(candidates, treeName)
+		}
+	}
+	sort.Strings(candidates)
+
+	switch len(candidates) {
+	case 0:
+		return "", fmt.Errorf("template %q not found", name)
+	case 1:
+		return candidates[0], nil
+	default:
+		return "", fmt.Errorf("template %q is ambiguous: %s", name, strings.Join(candidates, ", "))
+	}
+}
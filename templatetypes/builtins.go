@@ -5,12 +5,24 @@ import (
 	"go/types"
 )
 
-// funcChecker is a function that checks the arguments of a function.
-// args must be well-typed (i.e. non-nil).
-type funcChecker func(dot types.Type, args []types.Type) (types.Type, error)
+// FuncChecker is a function that checks the arguments of a function and returns the type of its result.
+// args are the types of the arguments including the final value of the pipeline, if any,
+// and must be well-typed (i.e. non-nil).
+// The result type may be nil if it cannot be determined statically.
+type FuncChecker func(dot types.Type, args []types.Type) (types.Type, error)
 
-var builtinFuncs = map[string]funcChecker{
-	"and":      stubBuiltinFunc(types.Typ[types.Bool]),
+// SignatureChecker returns a FuncChecker for functions of signature sig.
+func SignatureChecker(sig *types.Signature) FuncChecker {
+	return func(dot types.Type, args []types.Type) (types.Type, error) {
+		if err := checkArgs(sig, args); err != nil {
+			return nil, err
+		}
+		return resultType(sig)
+	}
+}
+
+var builtinFuncs = map[string]FuncChecker{
+	"and":      checkBuiltinAndOr,
 	"call":     checkBuiltinCall,
 	"html":     stubBuiltinFunc(types.Typ[types.String]),
 	"index":    checkBuiltinIndex,
@@ -18,19 +30,19 @@ var builtinFuncs = map[string]funcChecker{
 	"js":       stubBuiltinFunc(types.Typ[types.String]),
 	"len":      checkBuiltinLen,
 	"not":      stubBuiltinFunc(types.Typ[types.Bool]),
-	"or":       stubBuiltinFunc(types.Typ[types.Bool]),
+	"or":       checkBuiltinAndOr,
 	"print":    stubBuiltinFunc(types.Typ[types.String]),
-	"printf":   stubBuiltinFunc(types.Typ[types.String]),
+	"printf":   checkBuiltinPrintf,
 	"println":  stubBuiltinFunc(types.Typ[types.String]),
 	"urlquery": stubBuiltinFunc(types.Typ[types.String]),
 
 	// Comparisons
-	"eq": stubBuiltinFunc(types.Typ[types.Bool]), // ==
-	"ge": stubBuiltinFunc(types.Typ[types.Bool]), // >=
-	"gt": stubBuiltinFunc(types.Typ[types.Bool]), // >
-	"le": stubBuiltinFunc(types.Typ[types.Bool]), // <=
-	"lt": stubBuiltinFunc(types.Typ[types.Bool]), // <
-	"ne": stubBuiltinFunc(types.Typ[types.Bool]), // !=
+	"eq": checkBuiltinEq,      // ==
+	"ge": checkBuiltinOrdered, // >=
+	"gt": checkBuiltinOrdered, // >
+	"le": checkBuiltinOrdered, // <=
+	"lt": checkBuiltinOrdered, // <
+	"ne": checkBuiltinNe,      // !=
 }
 
 func stubBuiltinFunc(fixedType types.Type) func(dot types.Type, args []types.Type) (types.Type, error) {
@@ -39,18 +51,156 @@ func stubBuiltinFunc(fixedType types.Type) func(dot types.Type, args []types.Typ
 	}
 }
 
+// checkBuiltinAndOr checks and and or, which evaluate to one of their arguments.
+// If the arguments do not share a type, the result type is unknown (nil).
+func checkBuiltinAndOr(dot types.Type, args []types.Type) (types.Type, error) {
+	if len(args) < 1 {
+		return nil, fmt.Errorf("wrong number of args: want at least 1 got 0")
+	}
+
+	typ := args[0]
+	for _, arg := range args[1:] {
+		switch {
+		case types.Identical(typ, arg):
+		case isUntyped(arg) && types.AssignableTo(arg, typ):
+		case isUntyped(typ) && types.AssignableTo(typ, arg):
+			typ = arg
+		default:
+			return nil, nil
+		}
+	}
+
+	return typ, nil
+}
+
 func checkBuiltinCall(dot types.Type, args []types.Type) (types.Type, error) {
-	// FXIME: check types
-	fn, ok := args[0].(*types.Signature)
+	if len(args) < 1 {
+		return nil, fmt.Errorf("too few arguments")
+	}
+
+	fn, ok := args[0].Underlying().(*types.Signature)
 	if !ok {
 		return nil, fmt.Errorf("expected function type, got %s", args[0])
 	}
-	return fn.Results().At(0).Type(), nil
+	if err := checkCallArgs(fn, args[1:]); err != nil {
+		return nil, err
+	}
+	return resultType(fn)
+}
+
+// checkCallArgs checks args passed to fn by call. Unlike the arguments of functions and methods,
+// they are passed as evaluated, eg. constants of their default types and pointers without dereferenced,
+// and converted only between integer types.
+// ref. text/template.call(), prepareArg()
+func checkCallArgs(fn *types.Signature, args []types.Type) error {
+	params := fn.Params()
+	numIn := params.Len()
+	numFixed := numIn
+	if fn.Variadic() {
+		numFixed--
+		if len(args) < numFixed {
+			return fmt.Errorf("wrong number of args: want at least %d got %d", numFixed, len(args))
+		}
+	} else if len(args) != numIn {
+		return fmt.Errorf("wrong number of args: want %d got %d", numIn, len(args))
+	}
+
+	for i, arg := range args {
+		var param types.Type
+		if i < numFixed {
+			param = params.At(i).Type()
+		} else {
+			param = params.At(numIn - 1).Type().(*types.Slice).Elem()
+		}
+		if arg == nil {
+			continue
+		}
+		if isUntypedNil(arg) {
+			if !nillable(param) {
+				return fmt.Errorf("argument %d is nil; should be of type %s", i+1, param)
+			}
+			continue
+		}
+		arg = evaluatedType(arg)
+		if !preparableArg(arg, param) {
+			return fmt.Errorf("wrong type for argument %d: expected %s; got %s", i+1, param, arg)
+		}
+	}
+
+	return nil
+}
+
+// evaluatedType returns the type of the value text/template evaluates a constant of typ to.
+// ref. text/template.state.idealConstant()
+func evaluatedType(typ types.Type) types.Type {
+	basic, ok := typ.(*types.Basic)
+	if !ok {
+		return typ
+	}
+	switch basic.Kind() {
+	case types.UntypedInt, types.UntypedRune:
+		return types.Typ[types.Int]
+	case types.UntypedFloat:
+		return types.Typ[types.Float64]
+	case types.UntypedComplex:
+		return types.Typ[types.Complex128]
+	case types.UntypedString:
+		return types.Typ[types.String]
+	case types.UntypedBool:
+		return types.Typ[types.Bool]
+	}
+	return typ
+}
+
+// preparableArg reports whether a value of type arg can be passed as a parameter of type param by call.
+// Interface values are allowed if their dynamic type may fit.
+// ref. text/template.prepareArg()
+func preparableArg(arg, param types.Type) bool {
+	if types.AssignableTo(arg, param) {
+		return true
+	}
+	if types.IsInterface(arg) && (types.IsInterface(param) || types.AssignableTo(param, arg)) {
+		return true
+	}
+	return intLike(arg) && intLike(param)
+}
+
+func intLike(typ types.Type) bool {
+	basic, ok := typ.Underlying().(*types.Basic)
+	return ok && basic.Info()&types.IsInteger != 0 && basic.Info()&types.IsUntyped == 0
 }
 
+// ref. text/template.slice()
 func checkBuiltinSlice(dot types.Type, args []types.Type) (types.Type, error) {
-	// FIXME: check types
-	return args[0], nil
+	if len(args) < 1 {
+		return nil, fmt.Errorf("too few arguments")
+	}
+
+	item, indexes := args[0], args[1:]
+	if len(indexes) > 3 {
+		return nil, fmt.Errorf("too many slice indexes: %d", len(indexes))
+	}
+	for _, index := range indexes {
+		if !isInteger(index) {
+			return nil, fmt.Errorf("cannot index slice/array with type %s", index)
+		}
+	}
+
+	switch under := item.Underlying().(type) {
+	case *types.Basic:
+		if under.Info()&types.IsString != 0 {
+			if len(indexes) == 3 {
+				return nil, fmt.Errorf("cannot 3-index slice a string")
+			}
+			return item, nil
+		}
+	case *types.Slice:
+		return item, nil
+	case *types.Array:
+		return types.NewSlice(under.Elem()), nil
+	}
+
+	return nil, fmt.Errorf("can't slice item of type %s", item)
 }
 
 func checkBuiltinIndex(dot types.Type, args []types.Type) (types.Type, error) {
@@ -91,6 +241,16 @@ func checkBuiltinLen(dot types.Type, args []types.Type) (types.Type, error) {
 	return nil, fmt.Errorf("invalid argument type %s", arg)
 }
 
+func isUntyped(typ types.Type) bool {
+	basic, ok := typ.(*types.Basic)
+	return ok && basic.Info()&types.IsUntyped != 0
+}
+
+func isInteger(typ types.Type) bool {
+	basic, ok := typ.Underlying().(*types.Basic)
+	return ok && basic.Info()&types.IsInteger != 0
+}
+
 func indexTypeOf(typ types.Type) types.Type {
 	switch typ := typ.(type) {
 	case *types.Map:
@@ -125,3 +285,133 @@ func valueTypeOf(typ types.Type) types.Type {
 
 	return nil
 }
+
+type kind int
+
+const (
+	invalidKind kind = iota
+	boolKind
+	complexKind
+	intKind
+	floatKind
+	stringKind
+	uintKind
+)
+
+// basicKind returns the kind of typ as text/template compares values.
+// ok is false if the kind cannot be determined statically, i.e. typ is an interface.
+// ref. text/template.basicKind()
+func basicKind(typ types.Type) (k kind, ok bool) {
+	switch typ := typ.Underlying().(type) {
+	case *types.Interface:
+		return invalidKind, false
+	case *types.Basic:
+		info := typ.Info()
+		switch {
+		case info&types.IsBoolean != 0:
+			return boolKind, true
+		case info&types.IsInteger != 0 && info&types.IsUnsigned != 0:
+			return uintKind, true
+		case info&types.IsInteger != 0:
+			return intKind, true
+		case info&types.IsFloat != 0:
+			return floatKind, true
+		case info&types.IsComplex != 0:
+			return complexKind, true
+		case info&types.IsString != 0:
+			return stringKind, true
+		}
+	}
+	return invalidKind, true
+}
+
+// ref. text/template.eq()
+func checkBuiltinEq(dot types.Type, args []types.Type) (types.Type, error) {
+	if len(args) < 2 {
+		return nil, fmt.Errorf("missing argument for comparison")
+	}
+
+	arg1 := args[0]
+	k1, ok1 := basicKind(arg1)
+	for _, arg := range args[1:] {
+		// nil can be compared with values that can be nil, eg. {{eq .Ptr nil}}
+		if isUntypedNil(arg1) && (isUntypedNil(arg) || nillable(arg)) || isUntypedNil(arg) && nillable(arg1) {
+			continue
+		}
+		k2, ok2 := basicKind(arg)
+		if !ok1 || !ok2 {
+			continue
+		}
+		if k1 != k2 {
+			// Special case: Can compare integer values regardless of type's sign.
+			if k1 == intKind && k2 == uintKind || k1 == uintKind && k2 == intKind {
+				continue
+			}
+			return nil, fmt.Errorf("incompatible types for comparison: %s and %s", arg1, arg)
+		}
+		if k1 == invalidKind {
+			if !types.Identical(arg1, arg) {
+				return nil, fmt.Errorf("non-comparable types %s and %s", arg1, arg)
+			}
+			if !types.Comparable(arg) {
+				return nil, fmt.Errorf("non-comparable type %s", arg)
+			}
+		}
+	}
+
+	return types.Typ[types.Bool], nil
+}
+
+func isUntypedNil(typ types.Type) bool {
+	basic, ok := typ.(*types.Basic)
+	return ok && basic.Kind() == types.UntypedNil
+}
+
+// nillable reports whether values of typ can be nil.
+func nillable(typ types.Type) bool {
+	if typ == nil {
+		return false
+	}
+	switch typ.Underlying().(type) {
+	case *types.Pointer, *types.Map, *types.Slice, *types.Chan, *types.Signature, *types.Interface:
+		return true
+	}
+	return false
+}
+
+// ref. text/template.ne()
+func checkBuiltinNe(dot types.Type, args []types.Type) (types.Type, error) {
+	if len(args) != 2 {
+		return nil, fmt.Errorf("wrong number of args: want 2 got %d", len(args))
+	}
+
+	return checkBuiltinEq(dot, args)
+}
+
+// checkBuiltinOrdered checks lt, le, gt and ge.
+// ref. text/template.lt()
+func checkBuiltinOrdered(dot types.Type, args []types.Type) (types.Type, error) {
+	if len(args) != 2 {
+		return nil, fmt.Errorf("wrong number of args: want 2 got %d", len(args))
+	}
+
+	for _, arg := range args {
+		if k, ok := basicKind(arg); ok {
+			switch k {
+			case invalidKind, boolKind, complexKind:
+				return nil, fmt.Errorf("invalid type for comparison: %s", arg)
+			}
+		}
+	}
+
+	k1, ok1 := basicKind(args[0])
+	k2, ok2 := basicKind(args[1])
+	if ok1 && ok2 && k1 != k2 {
+		// Special case: Can compare integer values regardless of type's sign.
+		if !(k1 == intKind && k2 == uintKind || k1 == uintKind && k2 == intKind) {
+			return nil, fmt.Errorf("incompatible types for comparison: %s and %s", args[0], args[1])
+		}
+	}
+
+	return types.Typ[types.Bool], nil
+}
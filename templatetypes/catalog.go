@@ -0,0 +1,93 @@
+package templatetypes
+
+import (
+	"fmt"
+	"go/token"
+	"go/types"
+	"sort"
+	"sync"
+)
+
+// A catalog is a named set of function checkers for functions commonly given by FuncMaps,
+// used when the FuncMap itself is not available to the checker.
+var (
+	catalogsMu sync.RWMutex
+	catalogs   = map[string]map[string]FuncChecker{}
+)
+
+// RegisterCatalog registers a catalog of function checkers by name,
+// which can be enabled by Checker.Catalogs.
+// Registering a catalog with the same name again adds the functions to it, overriding existing ones.
+func RegisterCatalog(name string, funcs map[string]FuncChecker) {
+	catalogsMu.Lock()
+	defer catalogsMu.Unlock()
+
+	catalog := catalogs[name]
+	if catalog == nil {
+		catalog = map[string]FuncChecker{}
+		catalogs[name] = catalog
+	}
+	for fname, check := range funcs {
+		catalog[fname] = check
+	}
+}
+
+// Catalogs returns the names of the registered catalogs.
+func Catalogs() []string {
+	catalogsMu.RLock()
+	defer catalogsMu.RUnlock()
+
+	names := make([]string, 0, len(catalogs))
+	for name := range catalogs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// loadCatalogs returns the functions of the catalogs enabled, where later catalogs override earlier ones.
+func (s *Checker) loadCatalogs() (map[string]FuncChecker, error) {
+	catalogsMu.RLock()
+	defer catalogsMu.RUnlock()
+
+	funcs := map[string]FuncChecker{}
+	for _, name := range s.Catalogs {
+		catalog, ok := catalogs[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown catalog %q", name)
+		}
+		for fname, check := range catalog {
+			funcs[fname] = check
+		}
+	}
+	return funcs, nil
+}
+
+// Helpers for building catalogs.
+
+var (
+	anyIfaceType   = types.Universe.Lookup("any").Type()
+	anySliceType   = types.NewSlice(anyIfaceType)
+	stringMapType  = types.NewMap(types.Typ[types.String], anyIfaceType)
+	stringListType = types.NewSlice(types.Typ[types.String])
+)
+
+func newTuple(typs ...types.Type) *types.Tuple {
+	vars := make([]*types.Var, len(typs))
+	for i, typ := range typs {
+		vars[i] = types.NewParam(token.NoPos, nil, "", typ)
+	}
+	return types.NewTuple(vars...)
+}
+
+// fn returns a FuncChecker for func(params...) result.
+func fn(result types.Type, params ...types.Type) FuncChecker {
+	return SignatureChecker(types.NewSignatureType(nil, nil, nil, newTuple(params...), newTuple(result), false))
+}
+
+// variadicFn returns a FuncChecker for func(params[0], ..., ...params[n-1]) result.
+func variadicFn(result types.Type, params ...types.Type) FuncChecker {
+	params = append([]types.Type{}, params...)
+	params[len(params)-1] = types.NewSlice(params[len(params)-1])
+	return SignatureChecker(types.NewSignatureType(nil, nil, nil, newTuple(params...), newTuple(result), true))
+}
@@ -0,0 +1,956 @@
+package templatetypes
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/constant"
+	"go/token"
+	"go/types"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/go/ast/astutil"
+	"golang.org/x/tools/go/packages"
+	"golang.org/x/tools/go/types/typeutil"
+)
+
+// TemplateSet is a set of templates built by a Template in Go source code,
+// eg. template.Must(template.New("page").Funcs(funcs).ParseFiles("page.tmpl")).
+type TemplateSet struct {
+	// name of the template, as given to New or the base name of the first file parsed
+	Name string
+
+	// paths of the files parsed by ParseFiles, ParseGlob or ParseFS
+	Files []string
+
+	// delimiters given to Delims, or empty for the defaults
+	LeftDelim, RightDelim string
+
+	// HTML is true if the template is an html/template one
+	HTML bool
+
+	// position of the variable holding the template, or of the expression building it
+	Pos token.Position
+
+	// type of data and FuncMap variables of the set in the forms of Checker.DotType and Checker.FuncMapVar,
+	// which override and are added to the ones of the Checker respectively.
+	// They are not found by FindTemplateSets but given by users, eg. by configuration.
+	DotType, FuncMapVar string
+
+	// names of the templates checked by CheckTemplateSet when no bindings execute the set.
+	// If empty, each of the files is checked.
+	EntryPoints []string
+
+	funcMaps []funcMapExpr
+
+	// the Checker with the files parsed by LoadPackages, to be reused by CheckTemplateSet
+	checker *Checker
+}
+
+// funcMapExpr is an expression given to Template.Funcs.
+type funcMapExpr struct {
+	pkg   *packages.Package
+	expr  ast.Expr
+	scope ast.Node // the function body enclosing expr, for local variables
+}
+
+func (set *TemplateSet) clone() *TemplateSet {
+	c := *set
+	c.Files = append([]string(nil), set.Files...)
+	c.EntryPoints = append([]string(nil), set.EntryPoints...)
+	c.funcMaps = append([]funcMapExpr(nil), set.funcMaps...)
+	c.checker = nil
+	return &c
+}
+
+// UnresolvedError is returned by FindTemplateSets with the sets found
+// when some templates cannot be determined statically.
+type UnresolvedError struct {
+	Errors []error
+}
+
+func (e *UnresolvedError) Error() string {
+	return errors.Join(e.Errors...).Error()
+}
+
+func (e *UnresolvedError) Unwrap() []error {
+	return e.Errors
+}
+
+// FindTemplateSets loads the Go packages matching patterns and returns the template sets built in them.
+// Templates whose files cannot be determined statically are not returned, but reported by an *UnresolvedError.
+func (s *Checker) FindTemplateSets(patterns ...string) ([]TemplateSet, error) {
+	paths, err := s.loadPatterns(patterns)
+	if err != nil {
+		return nil, err
+	}
+
+	var pkgs []*packages.Package
+	for _, path := range paths {
+		pkgs = append(pkgs, s.packages[path]...)
+	}
+	return s.templateSets(pkgs)
+}
+
+// PackageTemplateSets returns the template sets built in pkg, which is loaded by the caller
+// with packages.NeedName, NeedTypes, NeedTypesInfo and NeedSyntax, and optionally NeedEmbedFiles.
+// Templates whose files cannot be determined statically are not returned, but reported by an *UnresolvedError.
+func (s *Checker) PackageTemplateSets(pkg *packages.Package) ([]TemplateSet, error) {
+	s.addPackage(pkg)
+	return s.templateSets([]*packages.Package{pkg})
+}
+
+func (s *Checker) templateSets(pkgs []*packages.Package) ([]TemplateSet, error) {
+	var (
+		sets []TemplateSet
+		errs []error
+		seen = map[token.Position]bool{}
+	)
+	for _, pkg := range pkgs {
+		f := templateSetFinder{pkg: pkg, visiting: map[types.Object]bool{}}
+		for _, set := range f.findAll() {
+			// test variants of packages, loaded with Tests, share the same files
+			if seen[set.Pos] {
+				continue
+			}
+			seen[set.Pos] = true
+			sets = append(sets, *set)
+		}
+		errs = append(errs, f.errs...)
+	}
+
+	sort.Slice(sets, func(i, j int) bool {
+		return positionLess(sets[i].Pos, sets[j].Pos)
+	})
+
+	if len(errs) > 0 {
+		return sets, &UnresolvedError{Errors: dedupErrors(errs)}
+	}
+	return sets, nil
+}
+
+// TemplateSetChecker returns a new Checker for set, with the configuration of s and the files parsed.
+// The returned Checker shares the packages loaded with s.
+func (s *Checker) TemplateSetChecker(set *TemplateSet) (*Checker, error) {
+	if s.packages == nil {
+		// so that packages loaded by c are cached in s
+		s.packages = map[string][]*packages.Package{}
+	}
+
+	dotType := s.DotType
+	if set.DotType != "" {
+		dotType = set.DotType
+	}
+	funcMapVar := s.FuncMapVar
+	if set.FuncMapVar != "" {
+		funcMapVar = strings.TrimPrefix(funcMapVar+","+set.FuncMapVar, ",")
+	}
+
+	c := &Checker{
+		DotType:                 dotType,
+		FuncMapVar:              funcMapVar,
+		Catalogs:                s.Catalogs,
+		Funcs:                   s.Funcs,
+		AllowUndefinedFuncs:     s.AllowUndefinedFuncs,
+		AllowUndefinedTemplates: s.AllowUndefinedTemplates,
+		HTML:                    s.HTML || set.HTML,
+		LeftDelim:               set.LeftDelim,
+		RightDelim:              set.RightDelim,
+		Verbose:                 s.Verbose,
+		Importer:                s.Importer,
+		Tests:                   s.Tests,
+		packages:                s.packages,
+		fset:                    s.FileSet(),
+		setFuncMaps:             set.funcMaps,
+	}
+
+	readFile := os.ReadFile
+	if s.ReadFile != nil {
+		readFile = s.ReadFile
+	}
+	for _, file := range set.Files {
+		content, err := readFile(file)
+		if err != nil {
+			return nil, err
+		}
+		// as Template.ParseFiles, templates are named after the base names of the files
+		if err := c.parse(filepath.Base(file), file, bytes.NewReader(content)); err != nil {
+			return nil, err
+		}
+	}
+
+	return c, nil
+}
+
+// CheckTemplateSet checks set with the types of data of the bindings executing it,
+// or each of its files with unknown data types if there are none.
+// It returns the Checker for set, which formats the errors.
+func (s *Checker) CheckTemplateSet(set *TemplateSet, bindings []Binding) (*Checker, error) {
+	c := set.checker
+	if c == nil {
+		var err error
+		c, err = s.TemplateSetChecker(set)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var (
+		errs     []error
+		reported = map[string]bool{}
+	)
+	// templates shared by multiple entry points are checked multiple times,
+	// whose errors are reported once with the first callers
+	add := func(err error) {
+		if err == nil {
+			return
+		}
+		for _, err := range UnwrapErrors(err) {
+			key := err
+			if tcErr, ok := err.(TypeCheckError); ok {
+				tcErr.Callers = nil
+				key = tcErr
+			}
+			if msg := c.FormatError(key); !reported[msg] {
+				reported[msg] = true
+				errs = append(errs, err)
+			}
+		}
+	}
+
+	executed := false
+	checked := map[string]bool{}
+	for _, b := range bindings {
+		if b.Set == nil || b.Set.Pos != set.Pos {
+			continue
+		}
+		executed = true
+
+		key := fmt.Sprintf("%s\x00%v", b.Template, b.Dot)
+		if checked[key] {
+			continue
+		}
+		checked[key] = true
+
+		s.debugf(nil, "%s: checking %s with %v", b.Pos, b.Template, b.Dot)
+		add(c.CheckBinding(b))
+	}
+
+	if !executed {
+		entryPoints := set.EntryPoints
+		if len(entryPoints) == 0 {
+			for _, file := range set.Files {
+				entryPoints = append(entryPoints, filepath.Base(file))
+			}
+		}
+		for _, name := range entryPoints {
+			s.debugf(nil, "%s: checking %s", set.Pos, name)
+			add(c.Check(name))
+		}
+	}
+
+	return c, errors.Join(errs...)
+}
+
+// LoadPackages loads the packages referred to by s and sets at once, so that types from them are comparable
+// when the sets are checked by CheckTemplateSet. Otherwise packages are loaded by each check as needed,
+// and types from packages loaded separately, eg. ones of DotType of sets, are not identical to each other.
+// Sets with errors are skipped, which are to be reported by CheckTemplateSet.
+// The files of sets are parsed once, and the Checkers of sets are reused by CheckTemplateSet.
+func (s *Checker) LoadPackages(sets []TemplateSet) error {
+	paths := s.referredPackages()
+	for i := range sets {
+		c, err := s.TemplateSetChecker(&sets[i])
+		if err != nil {
+			continue
+		}
+		sets[i].checker = c
+		paths = append(paths, c.referredPackages()...)
+	}
+	return s.loadPackages(paths...)
+}
+
+// loadPatterns loads the packages matching patterns along with the packages referred to,
+// and returns the paths of the packages matched.
+func (s *Checker) loadPatterns(patterns []string) ([]string, error) {
+	// resolve patterns to package paths first, as loaded packages are cached by their paths
+	roots, err := packages.Load(&packages.Config{Mode: packages.NeedName}, patterns...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load packages %q: %w", patterns, err)
+	}
+	var paths []string
+	for _, pkg := range roots {
+		if len(pkg.Errors) > 0 {
+			return nil, fmt.Errorf("failed to load package %q: %v", pkg.PkgPath, pkg.Errors)
+		}
+		paths = append(paths, pkg.PkgPath)
+	}
+
+	// load with packages referred to, so that types from them are comparable
+	if err := s.loadPackages(append(paths, s.referredPackages()...)...); err != nil {
+		return nil, err
+	}
+
+	for _, path := range paths {
+		for _, pkg := range s.packages[path] {
+			if len(pkg.Errors) > 0 {
+				return nil, fmt.Errorf("failed to load package %q: %v", pkg.PkgPath, pkg.Errors)
+			}
+		}
+	}
+
+	return paths, nil
+}
+
+func positionLess(p, q token.Position) bool {
+	if p.Filename != q.Filename {
+		return p.Filename < q.Filename
+	}
+	return p.Offset < q.Offset
+}
+
+func dedupErrors(errs []error) []error {
+	seen := map[string]bool{}
+	var uniq []error
+	for _, err := range errs {
+		if !seen[err.Error()] {
+			seen[err.Error()] = true
+			uniq = append(uniq, err)
+		}
+	}
+	return uniq
+}
+
+// templateSetFinder evaluates expressions building Templates in a package.
+type templateSetFinder struct {
+	pkg      *packages.Package
+	visiting map[types.Object]bool
+	errs     []error
+}
+
+// findAll returns the template sets held by variables or built by expressions in the package.
+func (f *templateSetFinder) findAll() []*TemplateSet {
+	var sets []*TemplateSet
+
+	record := func(set *TemplateSet, err error) {
+		if err != nil {
+			f.errs = append(f.errs, err)
+		} else if set != nil && len(set.Files) > 0 {
+			sets = append(sets, set)
+		}
+	}
+
+	recorded := map[types.Object]bool{}
+	recordVars := func(idents []ast.Expr) bool {
+		found := false
+		for _, expr := range idents {
+			ident, ok := expr.(*ast.Ident)
+			if !ok {
+				continue
+			}
+			v, ok := f.pkg.TypesInfo.ObjectOf(ident).(*types.Var)
+			if !ok {
+				continue
+			}
+			if _, ok := isTemplateType(v.Type()); !ok {
+				continue
+			}
+			found = true
+			if !recorded[v] {
+				recorded[v] = true
+				record(f.eval(ident))
+			}
+		}
+		return found
+	}
+
+	for _, file := range f.pkg.Syntax {
+		ast.Inspect(file, func(node ast.Node) bool {
+			switch node := node.(type) {
+			case *ast.ValueSpec:
+				idents := make([]ast.Expr, len(node.Names))
+				for i, name := range node.Names {
+					idents[i] = name
+				}
+				if len(node.Values) > 0 && recordVars(idents) {
+					return false
+				}
+
+			case *ast.AssignStmt:
+				if recordVars(node.Lhs) {
+					return false
+				}
+
+			case *ast.CallExpr:
+				if sel, ok := node.Fun.(*ast.SelectorExpr); ok {
+					if ident, ok := astutil.Unparen(sel.X).(*ast.Ident); ok {
+						if _, ok := f.pkg.TypesInfo.ObjectOf(ident).(*types.Var); ok {
+							// method calls on variables are taken into account with the variables
+							return true
+						}
+					}
+				}
+				set, err := f.eval(node)
+				if set != nil || err != nil {
+					record(set, err)
+					return false
+				}
+			}
+			return true
+		})
+	}
+
+	return sets
+}
+
+// templateFunc returns the name of the function of text/template or html/template called by call,
+// and whether it is a method of Template.
+func (f *templateSetFinder) templateFunc(call *ast.CallExpr) (name string, method bool, html bool, ok bool) {
+	fn, ok := typeutil.Callee(f.pkg.TypesInfo, call).(*types.Func)
+	if !ok || fn.Pkg() == nil {
+		return "", false, false, false
+	}
+	switch fn.Pkg().Path() {
+	case "text/template":
+	case "html/template":
+		html = true
+	default:
+		return "", false, false, false
+	}
+
+	if recv := fn.Type().(*types.Signature).Recv(); recv != nil {
+		if _, ok := isTemplateType(recv.Type()); !ok {
+			return "", false, false, false
+		}
+		return fn.Name(), true, html, true
+	}
+
+	return fn.Name(), false, html, true
+}
+
+// eval returns the template set that expr evaluates to, or nil if expr does not build a Template.
+func (f *templateSetFinder) eval(expr ast.Expr) (*TemplateSet, error) {
+	switch expr := astutil.Unparen(expr).(type) {
+	case *ast.Ident:
+		v, ok := f.pkg.TypesInfo.ObjectOf(expr).(*types.Var)
+		if !ok || v.Pkg() != f.pkg.Types {
+			return nil, nil
+		}
+		return f.evalVar(v)
+
+	case *ast.CallExpr:
+		return f.evalCall(expr)
+	}
+
+	return nil, nil
+}
+
+// evalVar returns the template set held by v, from its initialization and the method calls on it.
+func (f *templateSetFinder) evalVar(v *types.Var) (*TemplateSet, error) {
+	if f.visiting[v] {
+		return nil, nil
+	}
+	f.visiting[v] = true
+	defer delete(f.visiting, v)
+
+	scope := f.scopeOf(v)
+
+	init := f.initializer(v, scope)
+	if init == nil {
+		return nil, nil
+	}
+	set, err := f.eval(init)
+	if set == nil || err != nil {
+		return set, err
+	}
+	set = set.clone()
+
+	for _, node := range scope {
+		ast.Inspect(node, func(n ast.Node) bool {
+			if err != nil {
+				return false
+			}
+			call, ok := n.(*ast.CallExpr)
+			if !ok {
+				return true
+			}
+			sel, ok := call.Fun.(*ast.SelectorExpr)
+			if !ok {
+				return true
+			}
+			ident, ok := astutil.Unparen(sel.X).(*ast.Ident)
+			if !ok || f.pkg.TypesInfo.ObjectOf(ident) != v {
+				return true
+			}
+			name, method, _, ok := f.templateFunc(call)
+			if ok && method && modifiesReceiver(name) {
+				set, err = f.apply(set, name, call)
+			}
+			return true
+		})
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	set.Pos = f.pkg.Fset.Position(v.Pos())
+	return set, nil
+}
+
+// modifiesReceiver reports whether the method name of Template modifies the receiver.
+func modifiesReceiver(name string) bool {
+	switch name {
+	case "Funcs", "Delims", "ParseFiles", "ParseGlob", "ParseFS":
+		return true
+	}
+	return false
+}
+
+// evalCall returns the template set that call evaluates to.
+func (f *templateSetFinder) evalCall(call *ast.CallExpr) (*TemplateSet, error) {
+	name, method, html, ok := f.templateFunc(call)
+	if !ok {
+		return nil, nil
+	}
+
+	var set *TemplateSet
+	if method {
+		recv := call.Fun.(*ast.SelectorExpr).X
+		base, err := f.eval(recv)
+		if base == nil || err != nil {
+			return nil, err
+		}
+		if ident, ok := astutil.Unparen(recv).(*ast.Ident); ok && modifiesReceiver(name) {
+			if _, ok := f.pkg.TypesInfo.ObjectOf(ident).(*types.Var); ok {
+				// already applied by evalVar
+				return base, nil
+			}
+		}
+		set = base.clone()
+	} else {
+		switch name {
+		case "Must":
+			// Must(t, err), or Must(f()) with f returning (*Template, error)
+			if len(call.Args) == 0 {
+				return nil, nil
+			}
+			return f.eval(call.Args[0])
+		case "New", "ParseFiles", "ParseGlob", "ParseFS":
+			set = &TemplateSet{HTML: html}
+		default:
+			return nil, nil
+		}
+	}
+
+	switch name {
+	case "New", "Lookup":
+		if len(call.Args) != 1 {
+			return nil, nil
+		}
+		if set.Name, ok = f.constString(call.Args[0]); !ok {
+			return nil, f.errorf(call.Args[0], "cannot resolve template name: must be a constant string")
+		}
+
+	case "Funcs", "Delims", "ParseFiles", "ParseGlob", "ParseFS":
+		var err error
+		if set, err = f.apply(set, name, call); err != nil {
+			return nil, err
+		}
+
+	case "Clone", "Option", "Parse":
+		// the set is not changed; texts given to Parse are not checked
+
+	default:
+		return nil, nil
+	}
+
+	set.Pos = f.pkg.Fset.Position(call.Pos())
+	return set, nil
+}
+
+// apply applies the method name called by call to set.
+func (f *templateSetFinder) apply(set *TemplateSet, name string, call *ast.CallExpr) (*TemplateSet, error) {
+	switch name {
+	case "Funcs":
+		if len(call.Args) == 1 {
+			set.funcMaps = append(set.funcMaps, funcMapExpr{
+				pkg:   f.pkg,
+				expr:  call.Args[0],
+				scope: f.enclosingFunc(call.Pos()),
+			})
+		}
+
+	case "Delims":
+		if len(call.Args) == 2 {
+			left, ok1 := f.constString(call.Args[0])
+			right, ok2 := f.constString(call.Args[1])
+			if !ok1 || !ok2 {
+				return nil, f.errorf(call, "cannot resolve delimiters: must be constant strings")
+			}
+			set.LeftDelim, set.RightDelim = left, right
+		}
+
+	case "ParseFiles", "ParseGlob", "ParseFS":
+		files, err := f.files(name, call)
+		if err != nil {
+			return nil, err
+		}
+		if set.Name == "" && len(files) > 0 {
+			set.Name = filepath.Base(files[0])
+		}
+		set.Files = append(set.Files, files...)
+	}
+
+	return set, nil
+}
+
+// files returns the paths of the files parsed by call of ParseFiles, ParseGlob or ParseFS.
+func (f *templateSetFinder) files(name string, call *ast.CallExpr) ([]string, error) {
+	switch name {
+	case "ParseFiles":
+		var files []string
+		for _, arg := range call.Args {
+			file, ok := f.constString(arg)
+			if !ok {
+				return nil, f.errorf(arg, "cannot resolve template file: must be a constant string")
+			}
+			path, err := f.resolvePath(file)
+			if err != nil {
+				return nil, f.errorf(arg, "%s", err)
+			}
+			files = append(files, path)
+		}
+		return files, nil
+
+	case "ParseGlob":
+		if len(call.Args) != 1 {
+			return nil, nil
+		}
+		pattern, ok := f.constString(call.Args[0])
+		if !ok {
+			return nil, f.errorf(call.Args[0], "cannot resolve template pattern: must be a constant string")
+		}
+		for _, dir := range f.baseDirs() {
+			if filepath.IsAbs(pattern) {
+				dir = ""
+			}
+			files, err := filepath.Glob(filepath.Join(dir, pattern))
+			if err != nil {
+				return nil, f.errorf(call.Args[0], "%s", err)
+			}
+			if len(files) > 0 {
+				return files, nil
+			}
+		}
+		return nil, f.errorf(call.Args[0], "pattern matches no files: %#q", pattern)
+
+	case "ParseFS":
+		if len(call.Args) == 0 {
+			return nil, nil
+		}
+		dir, embedded, err := f.fsDir(call.Args[0])
+		if err != nil {
+			return nil, err
+		}
+		var files []string
+		for _, arg := range call.Args[1:] {
+			pattern, ok := f.constString(arg)
+			if !ok {
+				return nil, f.errorf(arg, "cannot resolve template pattern: must be a constant string")
+			}
+			matches, err := filepath.Glob(filepath.Join(dir, filepath.FromSlash(pattern)))
+			if err != nil {
+				return nil, f.errorf(arg, "%s", err)
+			}
+			if embedded != nil {
+				// files on disk but not embedded are not in the file system
+				var files []string
+				for _, match := range matches {
+					if embedded[match] {
+						files = append(files, match)
+					}
+				}
+				matches = files
+			}
+			if len(matches) == 0 {
+				return nil, f.errorf(arg, "pattern matches no files: %#q", pattern)
+			}
+			files = append(files, matches...)
+		}
+		return files, nil
+	}
+
+	return nil, nil
+}
+
+// fsDir returns the directory that the file system expr evaluates to is rooted at,
+// and the files in it if they are limited to the ones embedded, or nil otherwise. It supports:
+//
+//	embed.FS variables (with //go:embed directives, rooted at the package directory)
+//	fs.Sub(fsys, "dir")
+//	os.DirFS("dir")
+func (f *templateSetFinder) fsDir(expr ast.Expr) (string, map[string]bool, error) {
+	expr = astutil.Unparen(expr)
+
+	if named, ok := f.pkg.TypesInfo.TypeOf(expr).(*types.Named); ok {
+		if obj := named.Obj(); obj.Pkg() != nil && obj.Pkg().Path() == "embed" && obj.Name() == "FS" {
+			var embedded map[string]bool
+			if ident, ok := expr.(*ast.Ident); ok {
+				if v, ok := f.pkg.TypesInfo.ObjectOf(ident).(*types.Var); ok {
+					embedded = f.embeddedFiles(v)
+				}
+			}
+			return packageDir(f.pkg), embedded, nil
+		}
+	}
+
+	switch expr := expr.(type) {
+	case *ast.Ident:
+		if v, ok := f.pkg.TypesInfo.ObjectOf(expr).(*types.Var); ok && !f.visiting[v] {
+			f.visiting[v] = true
+			defer delete(f.visiting, v)
+			if init := f.initializer(v, f.scopeOf(v)); init != nil {
+				return f.fsDir(init)
+			}
+		}
+
+	case *ast.CallExpr:
+		if fn, ok := typeutil.Callee(f.pkg.TypesInfo, expr).(*types.Func); ok && fn.Pkg() != nil {
+			switch fn.Pkg().Path() + "." + fn.Name() {
+			case "io/fs.Sub":
+				if len(expr.Args) == 2 {
+					dir, embedded, err := f.fsDir(expr.Args[0])
+					if err != nil {
+						return "", nil, err
+					}
+					if sub, ok := f.constString(expr.Args[1]); ok {
+						return filepath.Join(dir, filepath.FromSlash(sub)), embedded, nil
+					}
+				}
+			case "os.DirFS":
+				if len(expr.Args) == 1 {
+					if dir, ok := f.constString(expr.Args[0]); ok {
+						path, err := f.resolvePath(dir)
+						if err != nil {
+							return "", nil, f.errorf(expr, "%s", err)
+						}
+						return path, nil, nil
+					}
+				}
+			}
+		}
+	}
+
+	return "", nil, f.errorf(expr, "cannot resolve file system")
+}
+
+// embeddedFiles returns the paths of the files embedded in v by its //go:embed directives,
+// limited to pkg.EmbedFiles if the package is loaded with them, or nil if v has no directives.
+func (f *templateSetFinder) embeddedFiles(v *types.Var) map[string]bool {
+	patterns := f.embedPatterns(v)
+	if len(patterns) == 0 {
+		return nil
+	}
+
+	var pkgFiles map[string]bool
+	if len(f.pkg.EmbedFiles) > 0 {
+		pkgFiles = map[string]bool{}
+		for _, file := range f.pkg.EmbedFiles {
+			pkgFiles[file] = true
+		}
+	}
+
+	files := map[string]bool{}
+	dir := packageDir(f.pkg)
+	for _, pattern := range patterns {
+		matches, _ := filepath.Glob(filepath.Join(dir, filepath.FromSlash(pattern)))
+		for _, match := range matches {
+			// directories are embedded with the files in them
+			_ = filepath.WalkDir(match, func(path string, d fs.DirEntry, err error) error {
+				if err == nil && !d.IsDir() && (pkgFiles == nil || pkgFiles[path]) {
+					files[path] = true
+				}
+				return nil
+			})
+		}
+	}
+	return files
+}
+
+// embedPatterns returns the patterns of the //go:embed directives of v.
+func (f *templateSetFinder) embedPatterns(v *types.Var) []string {
+	for _, file := range f.pkg.Syntax {
+		for _, decl := range file.Decls {
+			decl, ok := decl.(*ast.GenDecl)
+			if !ok || decl.Tok != token.VAR {
+				continue
+			}
+			for _, spec := range decl.Specs {
+				spec := spec.(*ast.ValueSpec)
+				if len(spec.Names) != 1 || f.pkg.TypesInfo.Defs[spec.Names[0]] != v {
+					continue
+				}
+
+				doc := spec.Doc
+				if doc == nil && len(decl.Specs) == 1 {
+					doc = decl.Doc
+				}
+				if doc == nil {
+					return nil
+				}
+
+				var patterns []string
+				for _, c := range doc.List {
+					args, ok := strings.CutPrefix(c.Text, "//go:embed ")
+					if !ok {
+						continue
+					}
+					for _, arg := range strings.Fields(args) {
+						if unquoted, err := strconv.Unquote(arg); err == nil {
+							arg = unquoted
+						}
+						patterns = append(patterns, strings.TrimPrefix(arg, "all:"))
+					}
+				}
+				return patterns
+			}
+		}
+	}
+	return nil
+}
+
+// resolvePath resolves path relative to the package directory or the module root,
+// where programs are usually run.
+func (f *templateSetFinder) resolvePath(path string) (string, error) {
+	if filepath.IsAbs(path) {
+		return path, nil
+	}
+	for _, dir := range f.baseDirs() {
+		p := filepath.Join(dir, path)
+		if _, err := os.Stat(p); err == nil {
+			return p, nil
+		}
+	}
+	return "", fmt.Errorf("file not found: %s", path)
+}
+
+func (f *templateSetFinder) baseDirs() []string {
+	dir := packageDir(f.pkg)
+
+	var moduleDir string
+	if f.pkg.Module != nil {
+		moduleDir = f.pkg.Module.Dir
+	} else {
+		// packages not loaded by us may lack module information
+		for d := dir; d != "" && d != filepath.Dir(d); d = filepath.Dir(d) {
+			if _, err := os.Stat(filepath.Join(d, "go.mod")); err == nil {
+				moduleDir = d
+				break
+			}
+		}
+	}
+
+	if moduleDir == "" || moduleDir == dir {
+		return []string{dir}
+	}
+	return []string{dir, moduleDir}
+}
+
+func packageDir(pkg *packages.Package) string {
+	if len(pkg.Syntax) == 0 {
+		return ""
+	}
+	return filepath.Dir(pkg.Fset.Position(pkg.Syntax[0].Pos()).Filename)
+}
+
+// scopeOf returns the nodes where v may be initialized or modified:
+// the enclosing function of a local variable, or the whole package.
+func (f *templateSetFinder) scopeOf(v *types.Var) []ast.Node {
+	if v.Parent() != v.Pkg().Scope() {
+		if fn := f.enclosingFunc(v.Pos()); fn != nil {
+			return []ast.Node{fn}
+		}
+	}
+	nodes := make([]ast.Node, len(f.pkg.Syntax))
+	for i, file := range f.pkg.Syntax {
+		nodes[i] = file
+	}
+	return nodes
+}
+
+// enclosingFunc returns the body of the innermost function enclosing pos, if any.
+func (f *templateSetFinder) enclosingFunc(pos token.Pos) ast.Node {
+	for _, file := range f.pkg.Syntax {
+		if pos < file.Pos() || file.End() < pos {
+			continue
+		}
+		path, _ := astutil.PathEnclosingInterval(file, pos, pos)
+		for _, node := range path {
+			switch node := node.(type) {
+			case *ast.FuncLit:
+				return node.Body
+			case *ast.FuncDecl:
+				return node.Body
+			}
+		}
+	}
+	return nil
+}
+
+// initializer returns the first expression assigned to v in nodes, if any.
+func (f *templateSetFinder) initializer(v *types.Var, nodes []ast.Node) ast.Expr {
+	var init ast.Expr
+	for _, node := range nodes {
+		ast.Inspect(node, func(n ast.Node) bool {
+			if init != nil {
+				return false
+			}
+			switch n := n.(type) {
+			case *ast.ValueSpec:
+				for i, name := range n.Names {
+					if f.pkg.TypesInfo.Defs[name] == v {
+						init = rhsAt(n.Values, len(n.Names), i)
+					}
+				}
+			case *ast.AssignStmt:
+				for i, lhs := range n.Lhs {
+					if ident, ok := lhs.(*ast.Ident); ok && f.pkg.TypesInfo.ObjectOf(ident) == v {
+						init = rhsAt(n.Rhs, len(n.Lhs), i)
+					}
+				}
+			}
+			return true
+		})
+	}
+	return init
+}
+
+// rhsAt returns the expression assigned to the i-th of n variables.
+// A call returning multiple values, eg. template.ParseFiles, is assigned to the first variable.
+func rhsAt(rhs []ast.Expr, n, i int) ast.Expr {
+	if len(rhs) == n {
+		return rhs[i]
+	}
+	if len(rhs) == 1 && i == 0 {
+		return rhs[0]
+	}
+	return nil
+}
+
+func (f *templateSetFinder) constString(expr ast.Expr) (string, bool) {
+	tv := f.pkg.TypesInfo.Types[expr]
+	if tv.Value == nil || tv.Value.Kind() != constant.String {
+		return "", false
+	}
+	return constant.StringVal(tv.Value), true
+}
+
+func (f *templateSetFinder) errorf(node ast.Node, format string, args ...any) error {
+	return fmt.Errorf("%s: %s", f.pkg.Fset.Position(node.Pos()), fmt.Sprintf(format, args...))
+}
@@ -0,0 +1,446 @@
+package templatetypes
+
+import (
+	"fmt"
+	"go/ast"
+	"go/constant"
+	"go/token"
+	"go/types"
+	"strings"
+
+	"golang.org/x/tools/go/ast/astutil"
+	"golang.org/x/tools/go/packages"
+	"golang.org/x/tools/go/types/typeutil"
+)
+
+// funcMapVars returns the names of the FuncMap variables in FuncMapVar.
+func (s *Checker) funcMapVars() []string {
+	var names []string
+	for _, name := range strings.Split(s.FuncMapVar, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+func (s *Checker) loadFuncMap(fullName string) (map[string]*types.Signature, error) {
+	pkg, obj, err := s.lookup(fullName)
+	if err != nil {
+		return nil, err
+	}
+	if obj == nil {
+		return nil, fmt.Errorf("cannot find %s", fullName)
+	}
+
+	v, ok := obj.(*types.Var)
+	if !ok || !isFuncMapType(v.Type()) {
+		return nil, fmt.Errorf("%s is not a FuncMap", fullName)
+	}
+
+	if len(pkg.Syntax) == 0 && s.Importer != nil {
+		// imported without the source code
+		funcMap, err := s.Importer.ImportFuncMap(v)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", fullName, err)
+		}
+		return funcMap, nil
+	}
+
+	l := funcMapLoader{
+		checker: s,
+		funcMap: map[string]*types.Signature{},
+		visited: map[types.Object]bool{},
+	}
+	if err := l.loadVar(pkg, v); err != nil {
+		return nil, fmt.Errorf("%s: %w", fullName, err)
+	}
+
+	return l.funcMap, nil
+}
+
+// loadFuncMapExpr loads the FuncMap given to Template.Funcs.
+func (s *Checker) loadFuncMapExpr(fm funcMapExpr) (map[string]*types.Signature, error) {
+	l := funcMapLoader{
+		checker: s,
+		funcMap: map[string]*types.Signature{},
+		visited: map[types.Object]bool{},
+	}
+
+	var err error
+	if ident, ok := astutil.Unparen(fm.expr).(*ast.Ident); ok && fm.scope != nil {
+		if v, ok := fm.pkg.TypesInfo.Uses[ident].(*types.Var); ok && v.Parent() != v.Pkg().Scope() {
+			// local variable
+			err = l.loadAssignments(fm.pkg, v, []ast.Node{fm.scope})
+		} else {
+			err = l.loadExpr(fm.pkg, fm.expr)
+		}
+	} else {
+		err = l.loadExpr(fm.pkg, fm.expr)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return l.funcMap, nil
+}
+
+// PackageFuncMaps returns the entries of the exported FuncMap variables of pkg
+// and of the FuncMaps returned by its exported functions without parameters,
+// for packages importing pkg to be checked with an Importer.
+// pkg is loaded by the caller as for PackageTemplateSets. FuncMaps that cannot be resolved are omitted.
+func (s *Checker) PackageFuncMaps(pkg *packages.Package) map[types.Object]map[string]*types.Signature {
+	s.addPackage(pkg)
+
+	funcMaps := map[types.Object]map[string]*types.Signature{}
+	scope := pkg.Types.Scope()
+	for _, name := range scope.Names() {
+		l := funcMapLoader{
+			checker: s,
+			funcMap: map[string]*types.Signature{},
+			visited: map[types.Object]bool{},
+		}
+
+		obj := scope.Lookup(name)
+		var err error
+		switch obj := obj.(type) {
+		case *types.Var:
+			if !obj.Exported() || !isFuncMapType(obj.Type()) {
+				continue
+			}
+			err = l.loadVar(pkg, obj)
+		case *types.Func:
+			sig := obj.Type().(*types.Signature)
+			if !obj.Exported() || sig.Params().Len() != 0 || sig.Results().Len() != 1 || !isFuncMapType(sig.Results().At(0).Type()) {
+				continue
+			}
+			err = l.loadFunc(pkg, nil, obj)
+		default:
+			continue
+		}
+		if err != nil {
+			s.debugf(nil, "%s: %s", name, err)
+			continue
+		}
+		funcMaps[obj] = l.funcMap
+	}
+
+	return funcMaps
+}
+
+// isFuncMapType reports whether typ can be used as a FuncMap,
+// ie. text/template.FuncMap, html/template.FuncMap or map[string]any.
+func isFuncMapType(typ types.Type) bool {
+	m, ok := typ.Underlying().(*types.Map)
+	if !ok {
+		return false
+	}
+	key, ok := m.Key().Underlying().(*types.Basic)
+	if !ok || key.Kind() != types.String {
+		return false
+	}
+	elem, ok := m.Elem().Underlying().(*types.Interface)
+	return ok && elem.Empty()
+}
+
+// funcMapLoader collects the entries of a FuncMap by reading the Go source code building it.
+// Entries set later override the earlier ones.
+type funcMapLoader struct {
+	checker *Checker
+	funcMap map[string]*types.Signature
+	visited map[types.Object]bool
+}
+
+// loadVar collects the entries of a package-level variable v from its initializer
+// and the assignments to it in init functions.
+func (l *funcMapLoader) loadVar(pkg *packages.Package, v *types.Var) error {
+	if l.visited[v] {
+		return nil
+	}
+	l.visited[v] = true
+
+	// package-level variables are initialized before any init functions run, which run in the order of the files
+	var decls, inits []ast.Node
+	for _, f := range pkg.Syntax {
+		for _, decl := range f.Decls {
+			switch decl := decl.(type) {
+			case *ast.GenDecl:
+				if decl.Tok == token.VAR {
+					decls = append(decls, decl)
+				}
+			case *ast.FuncDecl:
+				if decl.Recv == nil && decl.Name.Name == "init" && decl.Body != nil {
+					inits = append(inits, decl.Body)
+				}
+			}
+		}
+	}
+
+	return l.loadAssignments(pkg, v, append(decls, inits...))
+}
+
+// loadAssignments collects the entries of variable v from the statements in nodes initializing or modifying it:
+//
+//	v := expr / var v = expr / v = expr
+//	v["key"] = fn
+//	maps.Copy(v, expr)
+func (l *funcMapLoader) loadAssignments(pkg *packages.Package, v types.Object, nodes []ast.Node) error {
+	isVar := func(expr ast.Expr) bool {
+		ident, ok := astutil.Unparen(expr).(*ast.Ident)
+		return ok && pkg.TypesInfo.ObjectOf(ident) == v
+	}
+
+	var err error
+	for _, node := range nodes {
+		ast.Inspect(node, func(n ast.Node) bool {
+			if err != nil {
+				return false
+			}
+
+			switch n := n.(type) {
+			case *ast.ValueSpec:
+				for i, name := range n.Names {
+					if pkg.TypesInfo.Defs[name] != v {
+						continue
+					}
+					if len(n.Values) == 0 {
+						continue
+					}
+					if len(n.Values) != len(n.Names) {
+						err = l.errorf(pkg, n, "cannot resolve FuncMap from multi-value initialization")
+						return false
+					}
+					err = l.loadExpr(pkg, n.Values[i])
+				}
+
+			case *ast.AssignStmt:
+				for i, lhs := range n.Lhs {
+					if isVar(lhs) {
+						if len(n.Rhs) != len(n.Lhs) {
+							err = l.errorf(pkg, n, "cannot resolve FuncMap from multi-value assignment")
+							return false
+						}
+						err = l.loadExpr(pkg, n.Rhs[i])
+					} else if index, ok := lhs.(*ast.IndexExpr); ok && isVar(index.X) {
+						if len(n.Rhs) != len(n.Lhs) {
+							err = l.errorf(pkg, n, "cannot resolve FuncMap entry from multi-value assignment")
+							return false
+						}
+						err = l.loadEntry(pkg, index.Index, n.Rhs[i])
+					}
+				}
+
+			case *ast.CallExpr:
+				if isMapsCopy(pkg.TypesInfo, n) && len(n.Args) == 2 && isVar(n.Args[0]) {
+					err = l.loadExpr(pkg, n.Args[1])
+				}
+			}
+
+			return err == nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// isMapsCopy reports whether call is a call to maps.Copy.
+func isMapsCopy(info *types.Info, call *ast.CallExpr) bool {
+	fn, ok := typeutil.Callee(info, call).(*types.Func)
+	if !ok || fn.Pkg() == nil || fn.Name() != "Copy" {
+		return false
+	}
+	path := fn.Pkg().Path()
+	return path == "maps" || path == "golang.org/x/exp/maps"
+}
+
+// loadExpr collects the entries of the FuncMap that expr evaluates to.
+func (l *funcMapLoader) loadExpr(pkg *packages.Package, expr ast.Expr) error {
+	switch expr := astutil.Unparen(expr).(type) {
+	case *ast.CompositeLit:
+		for _, elt := range expr.Elts {
+			kv, ok := elt.(*ast.KeyValueExpr)
+			if !ok {
+				return l.errorf(pkg, elt, "cannot resolve FuncMap entry")
+			}
+			if err := l.loadEntry(pkg, kv.Key, kv.Value); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case *ast.Ident:
+		return l.loadObject(pkg, expr, pkg.TypesInfo.Uses[expr])
+
+	case *ast.SelectorExpr:
+		// pkg.Var
+		return l.loadObject(pkg, expr, pkg.TypesInfo.Uses[expr.Sel])
+
+	case *ast.CallExpr:
+		if tv, ok := pkg.TypesInfo.Types[expr.Fun]; ok && tv.IsType() && len(expr.Args) == 1 {
+			// conversion, eg. template.FuncMap(m)
+			return l.loadExpr(pkg, expr.Args[0])
+		}
+
+		fn, ok := typeutil.Callee(pkg.TypesInfo, expr).(*types.Func)
+		if !ok {
+			return l.errorf(pkg, expr, "cannot resolve FuncMap from call")
+		}
+		return l.loadFunc(pkg, expr, fn)
+	}
+
+	return l.errorf(pkg, expr, "cannot resolve FuncMap from %T", expr)
+}
+
+// loadObject collects the entries of the FuncMap variable obj referred to by node.
+func (l *funcMapLoader) loadObject(pkg *packages.Package, node ast.Node, obj types.Object) error {
+	v, ok := obj.(*types.Var)
+	if !ok || v.Pkg() == nil || v.Parent() != v.Pkg().Scope() {
+		return l.errorf(pkg, node, "cannot resolve FuncMap from %s", obj)
+	}
+
+	if v.Pkg() != pkg.Types {
+		if l.checker.Importer != nil {
+			return l.importFuncMap(pkg, node, v)
+		}
+		declPkg, err := l.packageOf(v)
+		if err != nil {
+			return l.errorf(pkg, node, "%s", err)
+		}
+		// v is imported from the declaring package, which is loaded separately
+		pkg, v = declPkg, declPkg.Types.Scope().Lookup(v.Name()).(*types.Var)
+	}
+
+	return l.loadVar(pkg, v)
+}
+
+// loadFunc collects the entries of the FuncMaps returned by function fn, called at node.
+func (l *funcMapLoader) loadFunc(pkg *packages.Package, node ast.Node, fn *types.Func) error {
+	if l.visited[fn] {
+		return nil
+	}
+	l.visited[fn] = true
+
+	if fn.Type().(*types.Signature).Recv() != nil {
+		return l.errorf(pkg, node, "cannot resolve FuncMap from method %s", fn.FullName())
+	}
+
+	callerPkg := pkg
+	if fn.Pkg() != pkg.Types {
+		if l.checker.Importer != nil {
+			return l.importFuncMap(pkg, node, fn)
+		}
+		declPkg, err := l.packageOf(fn)
+		if err != nil {
+			return l.errorf(pkg, node, "%s", err)
+		}
+		// fn is imported from the declaring package, which is loaded separately
+		pkg, fn = declPkg, declPkg.Types.Scope().Lookup(fn.Name()).(*types.Func)
+	}
+
+	var decl *ast.FuncDecl
+	for _, f := range pkg.Syntax {
+		for _, d := range f.Decls {
+			if d, ok := d.(*ast.FuncDecl); ok && pkg.TypesInfo.Defs[d.Name] == fn {
+				decl = d
+			}
+		}
+	}
+	if decl == nil || decl.Body == nil {
+		return l.errorf(callerPkg, node, "cannot find the body of %s", fn.FullName())
+	}
+
+	var err error
+	ast.Inspect(decl.Body, func(n ast.Node) bool {
+		if err != nil {
+			return false
+		}
+
+		switch n := n.(type) {
+		case *ast.FuncLit:
+			// return statements in closures are not of fn
+			return false
+
+		case *ast.ReturnStmt:
+			if len(n.Results) != 1 {
+				err = l.errorf(pkg, n, "cannot resolve FuncMap from return statement")
+				return false
+			}
+
+			result := astutil.Unparen(n.Results[0])
+			if ident, ok := result.(*ast.Ident); ok {
+				if v, ok := pkg.TypesInfo.Uses[ident].(*types.Var); ok && v.Parent() != v.Pkg().Scope() {
+					if v.Pos() < decl.Body.Pos() {
+						err = l.errorf(pkg, ident, "cannot resolve FuncMap from parameter %s", v.Name())
+						return false
+					}
+					// local variable
+					err = l.loadAssignments(pkg, v, []ast.Node{decl.Body})
+					return false
+				}
+			}
+			err = l.loadExpr(pkg, result)
+			return false
+		}
+
+		return true
+	})
+
+	return err
+}
+
+// loadEntry collects a FuncMap entry whose key is a constant string.
+func (l *funcMapLoader) loadEntry(pkg *packages.Package, key, value ast.Expr) error {
+	tv := pkg.TypesInfo.Types[key]
+	if tv.Value == nil || tv.Value.Kind() != constant.String {
+		return l.errorf(pkg, key, "cannot resolve FuncMap key: must be a constant string")
+	}
+	name := constant.StringVal(tv.Value)
+
+	typ := pkg.TypesInfo.TypeOf(value)
+	if typ == nil {
+		return l.errorf(pkg, value, "cannot resolve FuncMap entry %q: unknown type", name)
+	}
+	sig, ok := typ.Underlying().(*types.Signature)
+	if !ok {
+		return l.errorf(pkg, value, "cannot resolve FuncMap entry %q: not a function", name)
+	}
+
+	l.funcMap[name] = sig
+	return nil
+}
+
+// importFuncMap collects the entries of obj declared in another package, referred to by node, from the Importer.
+func (l *funcMapLoader) importFuncMap(pkg *packages.Package, node ast.Node, obj types.Object) error {
+	funcMap, err := l.checker.Importer.ImportFuncMap(obj)
+	if err != nil {
+		return l.errorf(pkg, node, "%s", err)
+	}
+	for name, sig := range funcMap {
+		l.funcMap[name] = sig
+	}
+	return nil
+}
+
+// packageOf returns the loaded package that declares obj, loading it if needed.
+func (l *funcMapLoader) packageOf(obj types.Object) (*packages.Package, error) {
+	path := obj.Pkg().Path()
+	if err := l.checker.loadPackages(path); err != nil {
+		return nil, err
+	}
+	for _, pkg := range l.checker.packages[path] {
+		if pkg.Types.Scope().Lookup(obj.Name()) != nil {
+			return pkg, nil
+		}
+	}
+	return nil, fmt.Errorf("cannot load package %q", path)
+}
+
+func (l *funcMapLoader) errorf(pkg *packages.Package, node ast.Node, format string, args ...any) error {
+	if pkg == nil || node == nil {
+		return fmt.Errorf(format, args...)
+	}
+	return fmt.Errorf("%s: %s", pkg.Fset.Position(node.Pos()), fmt.Sprintf(format, args...))
+}
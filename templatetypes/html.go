@@ -0,0 +1,367 @@
+package templatetypes
+
+import (
+	"go/types"
+	"strings"
+	"text/template/parse"
+)
+
+// htmlState is a coarse approximation of the states of html/template's contextual autoescaper.
+type htmlState int
+
+const (
+	htmlStateText        htmlState = iota // HTML text
+	htmlStateTag                          // inside a start tag, outside attribute values
+	htmlStateAttrName                     // inside an attribute name
+	htmlStateAfterName                    // after an attribute name, before '='
+	htmlStateBeforeValue                  // after '=', before an attribute value
+	htmlStateAttr                         // inside an attribute value
+	htmlStateComment                      // inside <!-- -->
+	htmlStateRCDATA                       // inside <textarea> or <title>
+	htmlStateJS                           // inside <script>
+	htmlStateCSS                          // inside <style>
+)
+
+// attrType is the kind of the attribute value being written.
+type attrType int
+
+const (
+	attrNormal attrType = iota
+	attrURL
+	attrJS
+	attrCSS
+	attrSrcset
+)
+
+// htmlContext is the escaping context at some point of an HTML template.
+type htmlContext struct {
+	state   htmlState
+	attr    attrType
+	delim   byte   // quote character of the attribute value, or 0 if unquoted
+	element string // name of the element whose content is special (script, style, textarea, title)
+}
+
+// ref. html/template.attrTypeMap
+var urlAttrs = map[string]bool{
+	"action":     true,
+	"archive":    true,
+	"background": true,
+	"cite":       true,
+	"classid":    true,
+	"codebase":   true,
+	"data":       true,
+	"formaction": true,
+	"href":       true,
+	"icon":       true,
+	"longdesc":   true,
+	"manifest":   true,
+	"poster":     true,
+	"profile":    true,
+	"src":        true,
+	"usemap":     true,
+	"xmlns":      true,
+}
+
+func attrTypeOf(name string) attrType {
+	name = strings.ToLower(name)
+	if p := strings.IndexByte(name, ':'); p >= 0 {
+		name = name[p+1:]
+	}
+	name = strings.TrimPrefix(name, "data-")
+
+	switch {
+	case strings.HasPrefix(name, "on"):
+		return attrJS
+	case name == "style":
+		return attrCSS
+	case name == "srcset":
+		return attrSrcset
+	case urlAttrs[name]:
+		return attrURL
+	}
+	return attrNormal
+}
+
+// String returns the name of the context used in diagnostics.
+func (c htmlContext) String() string {
+	switch c.state {
+	case htmlStateText:
+		return "HTML"
+	case htmlStateTag, htmlStateAttrName, htmlStateAfterName:
+		return "tag"
+	case htmlStateComment:
+		return "comment"
+	case htmlStateRCDATA:
+		return "RCDATA"
+	case htmlStateJS:
+		return "JS"
+	case htmlStateCSS:
+		return "CSS"
+	}
+
+	switch c.attr {
+	case attrURL:
+		return "URL"
+	case attrJS:
+		return "JS"
+	case attrCSS:
+		return "CSS"
+	case attrSrcset:
+		return "srcset"
+	}
+	return "attribute"
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f'
+}
+
+func isTagNameChar(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '-' || c == ':'
+}
+
+// scan returns the context after text written in context c.
+func (c htmlContext) scan(text string) htmlContext {
+	for i := 0; i < len(text); {
+		switch c.state {
+		case htmlStateText:
+			p := strings.IndexByte(text[i:], '<')
+			if p < 0 {
+				return c
+			}
+			i += p + 1
+			if strings.HasPrefix(text[i:], "!--") {
+				c.state = htmlStateComment
+				i += 3
+				continue
+			}
+			end := i
+			if end < len(text) && text[end] == '/' {
+				// end tag
+				if q := strings.IndexByte(text[i:], '>'); q >= 0 {
+					i += q + 1
+				} else {
+					i = len(text)
+				}
+				continue
+			}
+			for end < len(text) && isTagNameChar(text[end]) {
+				end++
+			}
+			if end == i {
+				continue
+			}
+			c.element = ""
+			switch name := strings.ToLower(text[i:end]); name {
+			case "script", "style", "textarea", "title":
+				c.element = name
+			}
+			c.state = htmlStateTag
+			i = end
+
+		case htmlStateTag:
+			switch ch := text[i]; {
+			case isSpace(ch), ch == '/':
+				i++
+			case ch == '>':
+				i++
+				c.attr, c.delim = attrNormal, 0
+				switch c.element {
+				case "script":
+					c.state = htmlStateJS
+				case "style":
+					c.state = htmlStateCSS
+				case "textarea", "title":
+					c.state = htmlStateRCDATA
+				default:
+					c.state = htmlStateText
+				}
+			default:
+				end := i
+				for end < len(text) && !isSpace(text[end]) && text[end] != '=' && text[end] != '>' && text[end] != '/' {
+					end++
+				}
+				c.attr = attrTypeOf(text[i:end])
+				c.state = htmlStateAttrName
+				if end < len(text) {
+					c.state = htmlStateAfterName
+				}
+				i = end
+			}
+
+		case htmlStateAttrName:
+			// continuation of an attribute name split by an action
+			for i < len(text) && !isSpace(text[i]) && text[i] != '=' && text[i] != '>' && text[i] != '/' {
+				i++
+			}
+			if i < len(text) {
+				c.state = htmlStateAfterName
+			}
+
+		case htmlStateAfterName:
+			switch ch := text[i]; {
+			case isSpace(ch):
+				i++
+			case ch == '=':
+				c.state = htmlStateBeforeValue
+				i++
+			default:
+				c.state = htmlStateTag
+			}
+
+		case htmlStateBeforeValue:
+			switch ch := text[i]; {
+			case isSpace(ch):
+				i++
+			case ch == '"' || ch == '\'':
+				c.state, c.delim = htmlStateAttr, ch
+				i++
+			default:
+				c.state, c.delim = htmlStateAttr, 0
+			}
+
+		case htmlStateAttr:
+			if c.delim != 0 {
+				p := strings.IndexByte(text[i:], c.delim)
+				if p < 0 {
+					return c
+				}
+				i += p + 1
+				c.state, c.attr, c.delim = htmlStateTag, attrNormal, 0
+			} else {
+				for i < len(text) && !isSpace(text[i]) && text[i] != '>' {
+					i++
+				}
+				if i < len(text) {
+					c.state, c.attr = htmlStateTag, attrNormal
+				}
+			}
+
+		case htmlStateComment:
+			p := strings.Index(text[i:], "-->")
+			if p < 0 {
+				return c
+			}
+			i += p + 3
+			c.state = htmlStateText
+
+		case htmlStateRCDATA, htmlStateJS, htmlStateCSS:
+			p := indexEndTag(text[i:], c.element)
+			if p < 0 {
+				return c
+			}
+			// let htmlStateText consume the end tag
+			i += p
+			c.state, c.element = htmlStateText, ""
+		}
+	}
+
+	return c
+}
+
+// indexEndTag returns the index of the end tag of element in s, or -1.
+func indexEndTag(s, element string) int {
+	for i := 0; i+2+len(element) <= len(s); i++ {
+		if s[i] == '<' && s[i+1] == '/' && strings.EqualFold(s[i+2:i+2+len(element)], element) {
+			return i
+		}
+	}
+	return -1
+}
+
+// joinHTMLContexts returns the context after the branches of an {{if}}, {{with}} or {{range}} ending in a and b.
+// html/template fails to escape branches ending in different contexts, which is reported at node.
+func (s *Checker) joinHTMLContexts(node parse.Node, nodeType parse.NodeType, a, b htmlContext) htmlContext {
+	if !s.HTML || a == b {
+		return a
+	}
+
+	var name string
+	switch nodeType {
+	case parse.NodeIf:
+		name = "if"
+	case parse.NodeWith:
+		name = "with"
+	case parse.NodeRange:
+		name = "range"
+	}
+	s.errorf(node, "{{%s}} branches end in different contexts: %s, %s", name, a, b)
+	return a
+}
+
+// trustedContexts maps html/template's typed strings to the contexts they are meant for.
+// In other contexts, they are escaped as plain strings, which is unlikely to be intended.
+var trustedContexts = map[string][]string{
+	"HTML":     {"HTML"},
+	"HTMLAttr": {"tag"},
+	"JS":       {"JS"},
+	"JSStr":    {"JS"},
+	"CSS":      {"CSS"},
+	"URL":      {"URL", "srcset"},
+	"Srcset":   {"srcset"},
+}
+
+// trustedTypeName returns the name of typ if it is one of the typed strings of html/template, eg. "HTML".
+func trustedTypeName(typ types.Type) string {
+	named, ok := typ.(*types.Named)
+	if !ok {
+		return ""
+	}
+	obj := named.Obj()
+	if obj.Pkg() == nil || obj.Pkg().Path() != "html/template" {
+		return ""
+	}
+	if _, ok := trustedContexts[obj.Name()]; !ok {
+		return ""
+	}
+	return obj.Name()
+}
+
+// checkEscaping checks that a value of typ output by an action is safe in the current context.
+func (s *Checker) checkEscaping(node parse.Node, typ types.Type) {
+	if typ == nil {
+		return
+	}
+
+	name := trustedTypeName(typ)
+	if name == "" {
+		return
+	}
+
+	// values are elided in comments
+	if s.htmlCtx.state == htmlStateComment {
+		return
+	}
+
+	ctx := s.htmlCtx.String()
+	for _, c := range trustedContexts[name] {
+		if c == ctx {
+			return
+		}
+	}
+
+	s.errorf(node, "value of type %s is used in %s context, where it is not trusted", typ, ctx)
+}
+
+// checkTrustedConversion reports a call converting non-constant strings to one of html/template's typed strings,
+// which bypasses the contextual escaping.
+// args are the argument nodes, which may be shorter than argTypes if the final value of a pipeline is passed.
+func (s *Checker) checkTrustedConversion(node parse.Node, name string, args []parse.Node, argTypes []types.Type, result types.Type) {
+	if !s.HTML || result == nil || trustedTypeName(result) == "" {
+		return
+	}
+
+	for i, typ := range argTypes {
+		if i < len(args) {
+			if _, ok := args[i].(*parse.StringNode); ok {
+				continue
+			}
+		}
+		basic, ok := typ.Underlying().(*types.Basic)
+		if !ok || basic.Info()&types.IsString == 0 || trustedTypeName(typ) != "" {
+			continue
+		}
+		s.errorf(node, "function %s converts %s to %s, bypassing escaping", name, typ, result)
+		return
+	}
+}
@@ -0,0 +1,218 @@
+package templatetypes
+
+import (
+	"go/types"
+	"text/template/parse"
+)
+
+// Info holds the types computed while checking templates, for tools such as editors.
+// Maps in Info are allocated as needed.
+// Positions of nodes are given by Checker.Position.
+// Templates invoked multiple times are recorded with the types of the last invocation.
+type Info struct {
+	// Types maps the nodes of pipelines, commands and arguments to their types.
+	// Nodes of unknown types are not recorded.
+	Types map[parse.Node]types.Type
+
+	// Dots maps the nodes walked, including lists and texts, to the types of dot at them.
+	// Comments of @type annotations are mapped to the types annotated.
+	Dots map[parse.Node]types.Type
+
+	// Objects maps field, chain and variable nodes to the fields and methods denoted by their identifiers,
+	// ie. FieldNode.Ident, ChainNode.Field and VariableNode.Ident, in order.
+	// Elements are nil for identifiers not resolved to objects, such as variables and map keys.
+	Objects map[parse.Node][]types.Object
+}
+
+func (s *Checker) record(node parse.Node, dot, typ types.Type) {
+	if s.Info == nil || node == nil {
+		return
+	}
+
+	if dot != nil {
+		if s.Info.Dots == nil {
+			s.Info.Dots = map[parse.Node]types.Type{}
+		}
+		s.Info.Dots[node] = dot
+	}
+	if typ != nil {
+		if s.Info.Types == nil {
+			s.Info.Types = map[parse.Node]types.Type{}
+		}
+		s.Info.Types[node] = typ
+	}
+}
+
+// recordObjects records objs denoted by the trailing identifiers of node.
+func (s *Checker) recordObjects(node parse.Node, objs []types.Object) {
+	if s.Info == nil {
+		return
+	}
+
+	var n int
+	switch node := node.(type) {
+	case *parse.FieldNode:
+		n = len(node.Ident)
+	case *parse.ChainNode:
+		n = len(node.Field)
+	case *parse.VariableNode:
+		n = len(node.Ident)
+	default:
+		return
+	}
+	if n < len(objs) {
+		return
+	}
+
+	objects := make([]types.Object, n)
+	copy(objects[n-len(objs):], objs)
+
+	if s.Info.Objects == nil {
+		s.Info.Objects = map[parse.Node][]types.Object{}
+	}
+	s.Info.Objects[node] = objects
+}
+
+// TypeAt returns the type of dot and the type of the innermost pipeline, command or argument at offset,
+// as recorded by the last check. offset is the byte offset in the source of the template templateName,
+// and may be in any template defined in the same source. Types are nil if unknown, not checked or Info is not set.
+func (s *Checker) TypeAt(templateName string, offset int) (dot, typ types.Type) {
+	if s.Info == nil {
+		return nil, nil
+	}
+
+	tree := s.treeAt(templateName, offset)
+	if tree == nil || tree.Root == nil {
+		return nil, nil
+	}
+
+	dot = s.dotAt(tree.Root, offset, -1)
+	_, typ = s.nodeAt(tree, offset)
+	return dot, typ
+}
+
+// NodeAt returns the innermost pipeline, command or argument at offset with its type, as recorded by the last check,
+// in the same way as TypeAt. Of nodes sharing the same span, eg. the pipeline, the command and the field of {{.Field}},
+// the innermost one is returned. node is nil if no node of known type is at offset.
+func (s *Checker) NodeAt(templateName string, offset int) (node parse.Node, typ types.Type) {
+	if s.Info == nil {
+		return nil, nil
+	}
+
+	tree := s.treeAt(templateName, offset)
+	if tree == nil || tree.Root == nil {
+		return nil, nil
+	}
+
+	return s.nodeAt(tree, offset)
+}
+
+func (s *Checker) nodeAt(tree *parse.Tree, offset int) (innermost parse.Node, typ types.Type) {
+	var start, size int
+	inspect(tree.Root, func(node parse.Node) {
+		t, ok := s.Info.Types[node]
+		if !ok {
+			return
+		}
+		_, p := s.Position(node)
+		n := len(node.String())
+		if offset < p || p+n <= offset {
+			return
+		}
+		// pipelines and commands may share the spans with their arguments
+		if innermost == nil || n < size || n == size && p >= start && nodeDepth(node) > nodeDepth(innermost) {
+			innermost, start, size, typ = node, p, n, t
+		}
+	})
+	return innermost, typ
+}
+
+func nodeDepth(node parse.Node) int {
+	switch node.(type) {
+	case *parse.PipeNode:
+		return 0
+	case *parse.CommandNode:
+		return 1
+	}
+	return 2
+}
+
+// treeAt returns the tree containing offset among the ones parsed from the source of the template name.
+func (s *Checker) treeAt(name string, offset int) *parse.Tree {
+	tree := s.treeSet[name]
+	if tree == nil {
+		return nil
+	}
+
+	// the tree with the last top-level node starting before offset
+	found, pos := tree, -1
+	for _, t := range s.trees() {
+		if t.ParseName != tree.ParseName || t.Root == nil {
+			continue
+		}
+		for _, node := range t.Root.Nodes {
+			if p := int(node.Position()); p <= offset && p > pos {
+				found, pos = t, p
+			}
+		}
+	}
+	return found
+}
+
+// dotAt returns the type of dot at offset in list, which ends at end or -1 if unknown.
+func (s *Checker) dotAt(list *parse.ListNode, offset, end int) types.Type {
+	dot := s.Info.Dots[list]
+	for i, node := range list.Nodes {
+		if int(node.Position()) > offset {
+			break
+		}
+
+		var (
+			branch *parse.BranchNode
+			key    = node
+		)
+		switch node := node.(type) {
+		case *parse.IfNode:
+			branch = &node.BranchNode
+		case *parse.RangeNode:
+			branch = &node.BranchNode
+		case *parse.WithNode:
+			branch = &node.BranchNode
+		case *parse.ActionNode:
+			key = node.Pipe
+		case *parse.TemplateNode:
+			key = node.Pipe
+		}
+		if branch != nil {
+			key = branch.Pipe
+		}
+		// dot may be changed by @type annotations
+		if d, ok := s.Info.Dots[key]; ok {
+			dot = d
+		}
+		if branch == nil {
+			continue
+		}
+
+		branchEnd := end
+		if i+1 < len(list.Nodes) {
+			branchEnd = int(list.Nodes[i+1].Position())
+		}
+		if branchEnd >= 0 && offset >= branchEnd {
+			continue
+		}
+
+		if branch.ElseList != nil && offset >= int(branch.ElseList.Position()) {
+			return s.dotAt(branch.ElseList, offset, branchEnd)
+		}
+		if branch.List != nil && offset >= int(branch.List.Position()) {
+			end := branchEnd
+			if branch.ElseList != nil {
+				end = int(branch.ElseList.Position())
+			}
+			return s.dotAt(branch.List, offset, end)
+		}
+	}
+
+	return dot
+}
@@ -0,0 +1,185 @@
+package templatetypes
+
+import (
+	"fmt"
+	"go/types"
+	"strings"
+	"text/template/parse"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// Importer provides the packages a Checker refers to, which are type-checked by the caller, eg. an analysis driver.
+// Types from them are comparable to the ones of the packages given to PackageTemplateSets and PackageBindings.
+type Importer interface {
+	// ImportPackage returns the package of path, or nil if it is not available.
+	ImportPackage(path string) *types.Package
+
+	// ImportFuncMap returns the entries of obj declared in another package,
+	// a FuncMap variable or a function returning a FuncMap, as returned by PackageFuncMaps.
+	// Entries whose signatures are not available are nil.
+	ImportFuncMap(obj types.Object) (map[string]*types.Signature, error)
+}
+
+const loadMode = packages.NeedName | packages.NeedTypes | packages.NeedTypesInfo | packages.NeedSyntax | packages.NeedModule | packages.NeedEmbedFiles
+
+// loadPackages loads the packages of paths that are not loaded yet.
+// Packages loaded at once share types of their dependencies.
+func (s *Checker) loadPackages(paths ...string) error {
+	if s.packages == nil {
+		s.packages = map[string][]*packages.Package{}
+	}
+
+	var patterns []string
+	for _, path := range paths {
+		if _, ok := s.packages[path]; ok {
+			continue
+		}
+		s.packages[path] = nil
+		patterns = append(patterns, path)
+	}
+	if len(patterns) == 0 {
+		return nil
+	}
+
+	if s.Importer != nil {
+		for _, path := range patterns {
+			if pkg := s.Importer.ImportPackage(path); pkg != nil {
+				s.packages[path] = []*packages.Package{{ID: path, Name: pkg.Name(), PkgPath: path, Types: pkg, Fset: s.FileSet()}}
+			}
+		}
+		return nil
+	}
+
+	s.debugf(nil, "loading packages: %v", patterns)
+
+	pkgs, err := packages.Load(&packages.Config{
+		Mode:  loadMode,
+		Fset:  s.FileSet(),
+		Tests: s.Tests,
+	}, patterns...)
+	if err != nil {
+		return fmt.Errorf("failed to load packages %q: %w", patterns, err)
+	}
+	for _, pkg := range pkgs {
+		s.packages[pkg.PkgPath] = append(s.packages[pkg.PkgPath], pkg)
+	}
+
+	return nil
+}
+
+// addPackage adds pkg loaded by the caller to the packages loaded.
+func (s *Checker) addPackage(pkg *packages.Package) {
+	if s.packages == nil {
+		s.packages = map[string][]*packages.Package{}
+	}
+	if len(s.packages[pkg.PkgPath]) == 0 {
+		s.packages[pkg.PkgPath] = []*packages.Package{pkg}
+	}
+}
+
+// lookup finds the package-level object specified by fullName (path/to/pkg.name), loading its package if needed.
+// obj is nil if the package does not have the object.
+func (s *Checker) lookup(fullName string) (pkg *packages.Package, obj types.Object, err error) {
+	p := strings.LastIndex(fullName, ".")
+	if p < 0 {
+		return nil, nil, fmt.Errorf("invalid name %q: must be in the form of path/to/pkg.name", fullName)
+	}
+	pkgPath, name := fullName[:p], fullName[p+1:]
+
+	if err := s.loadPackages(pkgPath); err != nil {
+		return nil, nil, err
+	}
+
+	pkgs := s.packages[pkgPath]
+	if len(pkgs) == 0 {
+		return nil, nil, fmt.Errorf("failed to load package %q", pkgPath)
+	}
+	if pkgs[0].Errors != nil {
+		return nil, nil, fmt.Errorf("failed to load package %q: %v", pkgPath, pkgs[0].Errors)
+	}
+
+	for _, pkg := range pkgs {
+		if obj := pkg.Types.Scope().Lookup(name); obj != nil {
+			return pkg, obj, nil
+		}
+	}
+
+	return pkgs[0], nil, nil
+}
+
+// referredPackages returns the paths of the packages the checker refers to for types.
+func (s *Checker) referredPackages() []string {
+	var paths []string
+	add := func(fullName string) {
+		fullName = strings.TrimLeft(fullName, "*")
+		if p := strings.LastIndex(fullName, "."); p >= 0 {
+			paths = append(paths, fullName[:p])
+		}
+	}
+
+	add(s.DotType)
+	for _, name := range s.funcMapVars() {
+		add(name)
+	}
+	for _, tree := range s.trees() {
+		inspect(tree.Root, func(node parse.Node) {
+			if comment, ok := node.(*parse.CommentNode); ok {
+				if m := rxAnnotation.FindStringSubmatch(comment.Text); m != nil && (m[1] == "type" || m[1] == "param") {
+					add(m[2])
+				}
+			}
+		})
+	}
+
+	return paths
+}
+
+// inspect calls f for node and each of the nodes in it, including pipelines, recursively.
+func inspect(node parse.Node, f func(parse.Node)) {
+	f(node)
+
+	var children []parse.Node
+	switch node := node.(type) {
+	case *parse.ListNode:
+		for _, n := range node.Nodes {
+			children = append(children, n)
+		}
+	case *parse.ActionNode:
+		children = []parse.Node{node.Pipe}
+	case *parse.IfNode:
+		children = []parse.Node{node.Pipe, node.List, node.ElseList}
+	case *parse.RangeNode:
+		children = []parse.Node{node.Pipe, node.List, node.ElseList}
+	case *parse.WithNode:
+		children = []parse.Node{node.Pipe, node.List, node.ElseList}
+	case *parse.TemplateNode:
+		children = []parse.Node{node.Pipe}
+	case *parse.PipeNode:
+		for _, v := range node.Decl {
+			children = append(children, v)
+		}
+		for _, cmd := range node.Cmds {
+			children = append(children, cmd)
+		}
+	case *parse.CommandNode:
+		children = node.Args
+	case *parse.ChainNode:
+		children = []parse.Node{node.Node}
+	}
+
+	for _, child := range children {
+		// typed nils, eg. ElseList
+		switch child := child.(type) {
+		case *parse.ListNode:
+			if child == nil {
+				continue
+			}
+		case *parse.PipeNode:
+			if child == nil {
+				continue
+			}
+		}
+		inspect(child, f)
+	}
+}
@@ -0,0 +1,262 @@
+package templatetypes
+
+import (
+	"fmt"
+	"go/types"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// checkBuiltinPrintf checks printf without looking into its format.
+// The format is checked by checkPrintf only when it is a string literal.
+func checkBuiltinPrintf(dot types.Type, args []types.Type) (types.Type, error) {
+	if len(args) < 1 {
+		return nil, fmt.Errorf("wrong number of args: want at least 1 got 0")
+	}
+	if !assignableArg(args[0], types.Typ[types.String]) {
+		return nil, fmt.Errorf("wrong type for argument 1: expected string; got %s", args[0])
+	}
+
+	return types.Typ[types.String], nil
+}
+
+type printfArgType int
+
+const (
+	argBool printfArgType = 1 << iota
+	argInt
+	argRune
+	argString
+	argFloat
+	argComplex
+	argPointer
+	anyType printfArgType = ^0
+)
+
+// ref. golang.org/x/tools/go/analysis/passes/printf.printVerbs
+var printVerbs = map[rune]printfArgType{
+	'b': argInt | argFloat | argComplex | argPointer,
+	'c': argRune | argInt,
+	'd': argInt | argPointer,
+	'e': argFloat | argComplex,
+	'E': argFloat | argComplex,
+	'f': argFloat | argComplex,
+	'F': argFloat | argComplex,
+	'g': argFloat | argComplex,
+	'G': argFloat | argComplex,
+	'o': argInt | argPointer,
+	'O': argInt | argPointer,
+	'p': argPointer,
+	'q': argRune | argInt | argString,
+	's': argString,
+	't': argBool,
+	'T': anyType,
+	'U': argRune | argInt,
+	'v': anyType,
+	'x': argRune | argInt | argString | argPointer | argFloat | argComplex,
+	'X': argRune | argInt | argString | argPointer | argFloat | argComplex,
+}
+
+// checkPrintf checks the verbs in format against args, the types of the arguments following the format,
+// like go vet's printf check does.
+func checkPrintf(format string, args []types.Type) error {
+	argNum := 0
+	indexed := false
+
+	for i := 0; i < len(format); {
+		if format[i] != '%' {
+			i++
+			continue
+		}
+
+		start := i
+		i++
+
+		// flags
+		for i < len(format) && strings.IndexByte("+-# 0", format[i]) >= 0 {
+			i++
+		}
+
+		// [n]: explicit argument index
+		parseIndex := func() error {
+			if i >= len(format) || format[i] != '[' {
+				return nil
+			}
+			end := strings.IndexByte(format[i:], ']')
+			if end < 0 {
+				return fmt.Errorf("format %s has unterminated argument index", format[start:])
+			}
+			n, err := strconv.Atoi(format[i+1 : i+end])
+			if err != nil || n < 1 {
+				return fmt.Errorf("format %s has invalid argument index", format[start:i+end+1])
+			}
+			argNum = n - 1
+			indexed = true
+			i += end + 1
+			return nil
+		}
+
+		// width and precision
+		for _, prefix := range []string{"", "."} {
+			if prefix != "" {
+				if i >= len(format) || format[i] != '.' {
+					break
+				}
+				i++
+			}
+			if err := parseIndex(); err != nil {
+				return err
+			}
+			if i < len(format) && format[i] == '*' {
+				if argNum >= len(args) {
+					return fmt.Errorf("format %s reads arg #%d, but call has %d args", format[start:i+1], argNum+1, len(args))
+				}
+				if !matchArgType(argInt, args[argNum], true, nil) {
+					return fmt.Errorf("format %s has arg #%d of wrong type %s for *", format[start:i+1], argNum+1, args[argNum])
+				}
+				argNum++
+				i++
+			} else {
+				for i < len(format) && '0' <= format[i] && format[i] <= '9' {
+					i++
+				}
+			}
+		}
+
+		if err := parseIndex(); err != nil {
+			return err
+		}
+
+		if i >= len(format) {
+			return fmt.Errorf("format %s is missing verb at end of string", format[start:])
+		}
+
+		verb, size := utf8.DecodeRuneInString(format[i:])
+		i += size
+		directive := format[start:i]
+
+		if verb == '%' {
+			continue
+		}
+
+		want, ok := printVerbs[verb]
+		if !ok {
+			return fmt.Errorf("format %s has unknown verb %c", directive, verb)
+		}
+
+		if argNum >= len(args) {
+			return fmt.Errorf("format %s reads arg #%d, but call has %d args", directive, argNum+1, len(args))
+		}
+
+		if !matchArgType(want, args[argNum], true, nil) {
+			return fmt.Errorf("format %s has arg #%d of wrong type %s", directive, argNum+1, args[argNum])
+		}
+
+		argNum++
+	}
+
+	if !indexed && argNum < len(args) {
+		return fmt.Errorf("call needs %d args but has %d args", argNum, len(args))
+	}
+
+	return nil
+}
+
+// matchArgType reports whether a value of type typ can be formatted with a verb accepting want.
+// ref. golang.org/x/tools/go/analysis/passes/printf.matchArgType
+func matchArgType(want printfArgType, typ types.Type, topLevel bool, seen map[types.Type]bool) bool {
+	if want == anyType {
+		return true
+	}
+
+	if hasMethod(typ, "Format") {
+		return true
+	}
+	if want&argString != 0 && (hasMethod(typ, "String") || hasMethod(typ, "Error")) {
+		return true
+	}
+
+	if seen[typ] {
+		return true
+	}
+	if seen == nil {
+		seen = map[types.Type]bool{}
+	}
+	seen[typ] = true
+
+	switch under := typ.Underlying().(type) {
+	case *types.Interface:
+		// the dynamic type is unknown
+		return true
+
+	case *types.Basic:
+		info := under.Info()
+		switch {
+		case under.Kind() == types.UntypedNil, under.Kind() == types.UnsafePointer:
+			return want&argPointer != 0
+		case info&types.IsBoolean != 0:
+			return want&argBool != 0
+		case info&types.IsInteger != 0:
+			return want&(argInt|argRune) != 0
+		case info&types.IsFloat != 0:
+			return want&argFloat != 0
+		case info&types.IsComplex != 0:
+			return want&argComplex != 0
+		case info&types.IsString != 0:
+			return want&argString != 0
+		}
+		return false
+
+	case *types.Slice:
+		if want&argPointer != 0 {
+			return true
+		}
+		// []byte is formatted like a string
+		if elem, ok := under.Elem().Underlying().(*types.Basic); ok && elem.Kind() == types.Byte && want&argString != 0 {
+			return true
+		}
+		return matchArgType(want, under.Elem(), false, seen)
+
+	case *types.Array:
+		return matchArgType(want, under.Elem(), false, seen)
+
+	case *types.Map:
+		if want&argPointer != 0 {
+			return true
+		}
+		return matchArgType(want, under.Key(), false, seen) && matchArgType(want, under.Elem(), false, seen)
+
+	case *types.Struct:
+		for i := 0; i < under.NumFields(); i++ {
+			if !matchArgType(want, under.Field(i).Type(), false, seen) {
+				return false
+			}
+		}
+		return true
+
+	case *types.Pointer:
+		if want&argPointer != 0 {
+			return true
+		}
+		// fmt prints &{...}, &[...] for pointers to composite values at the top level
+		if topLevel {
+			switch under.Elem().Underlying().(type) {
+			case *types.Struct, *types.Array, *types.Slice, *types.Map:
+				return matchArgType(want, under.Elem(), false, seen)
+			}
+		}
+		return false
+
+	case *types.Chan, *types.Signature:
+		return want&argPointer != 0
+	}
+
+	return false
+}
+
+func hasMethod(typ types.Type, name string) bool {
+	obj, _, _ := types.LookupFieldOrMethod(typ, false, nil, name)
+	_, ok := obj.(*types.Func)
+	return ok
+}
@@ -0,0 +1,195 @@
+package templatetypes
+
+import (
+	"fmt"
+	"go/types"
+	"sort"
+	"text/template/parse"
+)
+
+// TemplateSignature is the type of data a template is invoked with,
+// declared by @param or inferred from the {{template}} actions invoking it.
+type TemplateSignature struct {
+	Name string
+
+	// type of dot of the template, or nil if unknown, eg. callers disagree
+	Param types.Type
+
+	// Declared is true if Param is declared by @param
+	Declared bool
+
+	// {{template}} actions invoking the template, and the types of data passed by them
+	Calls []TemplateCall
+}
+
+type TemplateCall struct {
+	Node *parse.TemplateNode
+	Dot  types.Type
+}
+
+func (sig TemplateSignature) String() string {
+	param := "unknown"
+	if sig.Param != nil {
+		param = sig.Param.String()
+	}
+	return fmt.Sprintf("template %q (%s)", sig.Name, param)
+}
+
+func (s *Checker) recordCall(t *parse.TemplateNode, dot types.Type) {
+	if s.templateCalls == nil {
+		s.templateCalls = map[*parse.TemplateNode][]types.Type{}
+	}
+	for _, typ := range s.templateCalls[t] {
+		if typ == nil && dot == nil || typ != nil && dot != nil && types.Identical(typ, dot) {
+			return
+		}
+	}
+	s.templateCalls[t] = append(s.templateCalls[t], dot)
+}
+
+// Signatures returns the signatures of the templates invoked by {{template}} actions in all the templates parsed, sorted by name.
+// Types of data are the ones seen in the checks so far; calls inside templates invoked with multiple types of data
+// are recorded for each of them, and calls not walked by the checks are recorded with unknown types.
+func (s *Checker) Signatures() []TemplateSignature {
+	byName := map[string]*TemplateSignature{}
+	addCall := func(node *parse.TemplateNode, typ types.Type) {
+		sig := byName[node.Name]
+		if sig == nil {
+			sig = &TemplateSignature{Name: node.Name}
+			byName[node.Name] = sig
+		}
+		sig.Calls = append(sig.Calls, TemplateCall{Node: node, Dot: typ})
+	}
+	for _, tree := range s.trees() {
+		if tree.Root == nil {
+			continue
+		}
+		inspect(tree.Root, func(node parse.Node) {
+			t, ok := node.(*parse.TemplateNode)
+			if !ok {
+				return
+			}
+			typs, walked := s.templateCalls[t]
+			if !walked {
+				addCall(t, nil)
+			}
+			for _, typ := range typs {
+				addCall(t, typ)
+			}
+		})
+	}
+
+	sigs := make([]TemplateSignature, 0, len(byName))
+	for _, sig := range byName {
+		sort.SliceStable(sig.Calls, func(i, j int) bool {
+			ni, oi := s.Position(sig.Calls[i].Node)
+			nj, oj := s.Position(sig.Calls[j].Node)
+			if ni != nj {
+				return ni < nj
+			}
+			return oi < oj
+		})
+
+		if tree := s.treeSet[sig.Name]; tree != nil {
+			if param, typ := s.templateParam(tree); param != nil {
+				sig.Param, sig.Declared = typ, true
+			}
+		}
+		if !sig.Declared {
+			typs := make([]types.Type, len(sig.Calls))
+			for i, call := range sig.Calls {
+				typs[i] = call.Dot
+			}
+			sig.Param = commonType(typs)
+		}
+
+		sigs = append(sigs, *sig)
+	}
+	sort.Slice(sigs, func(i, j int) bool { return sigs[i].Name < sigs[j].Name })
+
+	return sigs
+}
+
+// commonType returns the type all of typs are identical or assignable to, if it is one of them.
+// Unknown types (nil) are ignored.
+func commonType(typs []types.Type) types.Type {
+	var common types.Type
+	for _, typ := range typs {
+		switch {
+		case typ == nil:
+		case common == nil, types.Identical(common, typ):
+			common = typ
+		case isInterface(common) && types.AssignableTo(typ, common):
+		case isInterface(typ) && types.AssignableTo(common, typ):
+			common = typ
+		default:
+			return nil
+		}
+	}
+	return common
+}
+
+func isInterface(typ types.Type) bool {
+	_, ok := typ.Underlying().(*types.Interface)
+	return ok
+}
+
+// checkCallers notes the types of data passed by other {{template}} actions in the errors of a template,
+// when the template is valid for them but not for the data passed by the actions reaching the errors.
+func (s *Checker) checkCallers() {
+	trees := make([]*parse.Tree, 0, len(s.visited))
+	for tree := range s.visited {
+		trees = append(trees, tree)
+	}
+	sort.Slice(trees, func(i, j int) bool { return trees[i].Name < trees[j].Name })
+
+	for _, tree := range trees {
+		var valid, invalid []*templateCheck
+		for _, check := range s.visited[tree] {
+			if check.dot == nil || check.param {
+				continue
+			}
+			if len(check.errors) == 0 {
+				valid = append(valid, check)
+			} else {
+				invalid = append(invalid, check)
+			}
+		}
+		if len(valid) == 0 || len(invalid) == 0 {
+			continue
+		}
+
+	invalidChecks:
+		for _, check := range invalid {
+			for _, v := range valid {
+				if types.Identical(check.dot, v.dot) {
+					// valid in another HTML context, see walkTemplateTree
+					continue invalidChecks
+				}
+			}
+			note := fmt.Sprintf(" (template %q is valid for data of type %s passed by other callers)", tree.Name, valid[0].dot)
+			for i, err := range s.errors {
+				if tcErr, ok := err.(TypeCheckError); ok && check.reached(tcErr) {
+					tcErr.Message += note
+					s.errors[i] = tcErr
+				}
+			}
+		}
+	}
+}
+
+// reached reports whether err is one of the errors of the check, reached by one of its calls.
+func (c *templateCheck) reached(err TypeCheckError) bool {
+	for _, e := range c.errors {
+		e, ok := e.(TypeCheckError)
+		if !ok || e.Node != err.Node || e.Message != err.Message || len(e.Callers) >= len(err.Callers) {
+			continue
+		}
+		for _, call := range c.calls {
+			if err.Callers[len(e.Callers)] == call {
+				return true
+			}
+		}
+	}
+	return false
+}
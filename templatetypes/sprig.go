@@ -0,0 +1,201 @@
+package templatetypes
+
+import (
+	"fmt"
+	"go/types"
+)
+
+// The "sprig" catalog has the functions of github.com/Masterminds/sprig/v3 TxtFuncMap().
+func init() {
+	var (
+		tString = types.Typ[types.String]
+		tInt    = types.Typ[types.Int]
+		tInt64  = types.Typ[types.Int64]
+		tFloat  = types.Typ[types.Float64]
+		tBool   = types.Typ[types.Bool]
+		tAny    = anyIfaceType
+	)
+
+	RegisterCatalog("sprig", map[string]FuncChecker{
+		// Strings
+		"abbrev":          fn(tString, tInt, tString),
+		"abbrevboth":      fn(tString, tInt, tInt, tString),
+		"trunc":           fn(tString, tInt, tString),
+		"trim":            fn(tString, tString),
+		"upper":           fn(tString, tString),
+		"lower":           fn(tString, tString),
+		"title":           fn(tString, tString),
+		"untitle":         fn(tString, tString),
+		"substr":          fn(tString, tInt, tInt, tString),
+		"repeat":          fn(tString, tInt, tString),
+		"trimAll":         fn(tString, tString, tString),
+		"trimSuffix":      fn(tString, tString, tString),
+		"trimPrefix":      fn(tString, tString, tString),
+		"nospace":         fn(tString, tString),
+		"initials":        fn(tString, tString),
+		"swapcase":        fn(tString, tString),
+		"shuffle":         fn(tString, tString),
+		"snakecase":       fn(tString, tString),
+		"camelcase":       fn(tString, tString),
+		"kebabcase":       fn(tString, tString),
+		"wrap":            fn(tString, tInt, tString),
+		"wrapWith":        fn(tString, tInt, tString, tString),
+		"contains":        fn(tBool, tString, tString),
+		"hasPrefix":       fn(tBool, tString, tString),
+		"hasSuffix":       fn(tBool, tString, tString),
+		"quote":           variadicFn(tString, tAny),
+		"squote":          variadicFn(tString, tAny),
+		"cat":             variadicFn(tString, tAny),
+		"indent":          fn(tString, tInt, tString),
+		"nindent":         fn(tString, tInt, tString),
+		"replace":         fn(tString, tString, tString, tString),
+		"plural":          fn(tString, tString, tString, tInt),
+		"toString":        fn(tString, tAny),
+		"toStrings":       fn(stringListType, tAny),
+		"join":            fn(tString, tString, tAny),
+		"split":           fn(types.NewMap(tString, tString), tString, tString),
+		"splitList":       fn(stringListType, tString, tString),
+		"splitn":          fn(types.NewMap(tString, tString), tString, tInt, tString),
+		"regexMatch":      fn(tBool, tString, tString),
+		"regexFind":       fn(tString, tString, tString),
+		"regexFindAll":    fn(stringListType, tString, tString, tInt),
+		"regexReplaceAll": fn(tString, tString, tString, tString),
+		"regexSplit":      fn(stringListType, tString, tString, tInt),
+		"b64enc":          fn(tString, tString),
+		"b64dec":          fn(tString, tString),
+		"b32enc":          fn(tString, tString),
+		"b32dec":          fn(tString, tString),
+		"sha1sum":         fn(tString, tString),
+		"sha256sum":       fn(tString, tString),
+		"adler32sum":      fn(tString, tString),
+		"randAlphaNum":    fn(tString, tInt),
+		"randAlpha":       fn(tString, tInt),
+		"randNumeric":     fn(tString, tInt),
+		"uuidv4":          fn(tString),
+
+		// Conversions
+		"atoi":      fn(tInt, tString),
+		"int":       fn(tInt, tAny),
+		"int64":     fn(tInt64, tAny),
+		"float64":   fn(tFloat, tAny),
+		"toDecimal": fn(tInt64, tAny),
+
+		// Math
+		"add":       variadicFn(tInt64, tAny),
+		"add1":      fn(tInt64, tAny),
+		"sub":       fn(tInt64, tAny, tAny),
+		"mul":       variadicFn(tInt64, tAny, tAny),
+		"div":       fn(tInt64, tAny, tAny),
+		"mod":       fn(tInt64, tAny, tAny),
+		"max":       variadicFn(tInt64, tAny, tAny),
+		"min":       variadicFn(tInt64, tAny, tAny),
+		"biggest":   variadicFn(tInt64, tAny, tAny),
+		"floor":     fn(tFloat, tAny),
+		"ceil":      fn(tFloat, tAny),
+		"round":     variadicFn(tFloat, tAny, tInt, tFloat),
+		"until":     fn(types.NewSlice(tInt), tInt),
+		"untilStep": fn(types.NewSlice(tInt), tInt, tInt, tInt),
+		"seq":       variadicFn(tString, tInt),
+
+		// Defaults
+		"default":      checkSprigDefault,
+		"empty":        fn(tBool, tAny),
+		"coalesce":     checkSprigCoalesce,
+		"ternary":      checkSprigTernary,
+		"toJson":       fn(tString, tAny),
+		"toPrettyJson": fn(tString, tAny),
+		"toRawJson":    fn(tString, tAny),
+
+		// Reflection
+		"typeOf":    fn(tString, tAny),
+		"typeIs":    fn(tBool, tString, tAny),
+		"kindOf":    fn(tString, tAny),
+		"kindIs":    fn(tBool, tString, tAny),
+		"deepEqual": fn(tBool, tAny, tAny),
+
+		// OS
+		"env":       fn(tString, tString),
+		"expandenv": fn(tString, tString),
+
+		// Dates; time.Time is not known here, so now yields an unknown type
+		"now":        checkUnknownResult,
+		"date":       fn(tString, tString, tAny),
+		"dateInZone": fn(tString, tString, tAny, tString),
+		"ago":        fn(tString, tAny),
+		"htmlDate":   fn(tString, tAny),
+
+		// Lists
+		"list":    variadicFn(anySliceType, tAny),
+		"first":   checkSprigListElem,
+		"last":    checkSprigListElem,
+		"rest":    fn(anySliceType, tAny),
+		"initial": fn(anySliceType, tAny),
+		"append":  fn(anySliceType, tAny, tAny),
+		"push":    fn(anySliceType, tAny, tAny),
+		"prepend": fn(anySliceType, tAny, tAny),
+		"reverse": fn(anySliceType, tAny),
+		"uniq":    fn(anySliceType, tAny),
+		"compact": fn(anySliceType, tAny),
+		"without": variadicFn(anySliceType, tAny, tAny),
+		"has":     fn(tBool, tAny, tAny),
+
+		// Dictionaries
+		"dict":   variadicFn(stringMapType, tAny),
+		"get":    fn(tAny, stringMapType, tString),
+		"set":    fn(stringMapType, stringMapType, tString, tAny),
+		"unset":  fn(stringMapType, stringMapType, tString),
+		"hasKey": fn(tBool, stringMapType, tString),
+		"keys":   variadicFn(stringListType, stringMapType),
+		"pluck":  variadicFn(anySliceType, tString, stringMapType),
+		"merge":  variadicFn(tAny, stringMapType, stringMapType),
+
+		"fail": fn(tString, tString),
+	})
+}
+
+func checkUnknownResult(dot types.Type, args []types.Type) (types.Type, error) {
+	return nil, nil
+}
+
+// checkSprigDefault checks "default DEFAULT GIVEN", which evaluates to either of the arguments.
+func checkSprigDefault(dot types.Type, args []types.Type) (types.Type, error) {
+	if len(args) < 1 {
+		return nil, fmt.Errorf("wrong number of args: want at least 1 got 0")
+	}
+	return checkBuiltinAndOr(dot, args)
+}
+
+// checkSprigCoalesce checks "coalesce ARGS...", which evaluates to one of the arguments.
+func checkSprigCoalesce(dot types.Type, args []types.Type) (types.Type, error) {
+	if len(args) == 0 {
+		return nil, nil
+	}
+	return checkBuiltinAndOr(dot, args)
+}
+
+// checkSprigTernary checks "ternary TRUE FALSE COND".
+func checkSprigTernary(dot types.Type, args []types.Type) (types.Type, error) {
+	if len(args) != 3 {
+		return nil, fmt.Errorf("wrong number of args: want 3 got %d", len(args))
+	}
+	if !assignableArg(args[2], types.Typ[types.Bool]) {
+		return nil, fmt.Errorf("wrong type for argument 3: expected bool; got %s", args[2])
+	}
+	return checkBuiltinAndOr(dot, args[:2])
+}
+
+// checkSprigListElem checks "first LIST" and "last LIST", which evaluate to an element of the list.
+func checkSprigListElem(dot types.Type, args []types.Type) (types.Type, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("wrong number of args: want 1 got %d", len(args))
+	}
+	switch list := args[0].Underlying().(type) {
+	case *types.Slice:
+		return list.Elem(), nil
+	case *types.Array:
+		return list.Elem(), nil
+	case *types.Interface:
+		return nil, nil
+	}
+	return nil, fmt.Errorf("cannot find element of type %s", args[0])
+}
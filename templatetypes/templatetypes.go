@@ -1,20 +1,18 @@
 package templatetypes
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
-	"go/ast"
 	"go/token"
 	"go/types"
 	"io"
 	"log"
 	"os"
 	"regexp"
-	"strconv"
 	"strings"
 	"text/template/parse"
 
-	"golang.org/x/tools/go/ast/astutil"
 	"golang.org/x/tools/go/packages"
 )
 
@@ -22,19 +20,75 @@ type Checker struct {
 	// default full annotated (path/to/pkg.type) type path of dot, if any
 	DotType string
 
-	// full annotated (path/to/pkg.name) variable that is a text/template.FuncMap for user-defined functions
+	// full annotated (path/to/pkg.name) variable that is a FuncMap for user-defined functions.
+	// Multiple variables can be specified separated by commas, and later ones override earlier ones.
+	// The variable may be of text/template.FuncMap, html/template.FuncMap or map[string]any.
 	FuncMapVar string
 
+	// names of the catalogs of functions to enable, eg. "sprig". See RegisterCatalog.
+	// Functions in FuncMapVar take precedence over them.
+	Catalogs []string
+
+	// checkers of functions whose types cannot be expressed by Go signatures, eg. ones depending on the argument types.
+	// They take precedence over builtin functions, FuncMapVar and Catalogs.
+	Funcs map[string]FuncChecker
+
 	AllowUndefinedFuncs     bool
 	AllowUndefinedTemplates bool
 
+	// HTML enables checks for html/template, which escapes values depending on the context
+	HTML bool
+
+	// action delimiters used to parse templates, as Template.Delims. Empty ones mean the defaults.
+	LeftDelim, RightDelim string
+
 	Verbose bool
 
+	// Importer, if set, provides the packages referred to instead of loading them by go/packages, eg. in analyzers.
+	Importer Importer
+
+	// Tests loads the test files of packages too, as packages.Config.Tests, so that types in them can be referred to.
+	Tests bool
+
+	// ReadFile, if set, reads the template files of template sets instead of os.ReadFile.
+	ReadFile func(filename string) ([]byte, error)
+
+	// Info, if set, records the types computed while checking. See also TypeAt.
+	Info *Info
+
 	errors  []error
 	vars    []variable
+	htmlCtx htmlContext
+	// @param annotation of the template being walked
+	param   *parse.CommentNode
 	treeSet map[string]*parse.Tree
-	visited map[*parse.Tree]bool
+	// non-empty definitions of the templates in the order parsed, ie. the base definitions of {{block}} and the overrides
+	variants map[string][]*parse.Tree
+	// names of the templates defined by {{block}}, whose variants are all checked
+	blocks  map[string]bool
+	visited map[*parse.Tree][]*templateCheck
 	funcMap map[string]*types.Signature
+	// FuncMaps given to the template set by Funcs, see TemplateSetChecker
+	setFuncMaps []funcMapExpr
+	catalog     map[string]FuncChecker
+	packages    map[string][]*packages.Package
+	fset        *token.FileSet
+	// types of data passed by {{template}} actions, see Signatures
+	templateCalls map[*parse.TemplateNode][]types.Type
+}
+
+// templateCheck is a check of a template invoked with a type of dot.
+type templateCheck struct {
+	dot     types.Type
+	param   bool        // dot is declared by @param
+	htmlCtx htmlContext // context in which the template is invoked, with HTML
+	done    bool
+	errors  []error
+	calls   []*parse.TemplateNode
+	// context at the end of the template, with HTML
+	htmlEnd htmlContext
+	// the check is compared to the base definition, see checkOverride
+	overrideChecked bool
 }
 
 type variable struct {
@@ -63,99 +117,83 @@ func (s *Checker) varType(name string) (types.Type, error) {
 type TypeCheckError struct {
 	Node    parse.Node
 	Message string
+
+	// {{template}} actions through which Node is reached from the entry point, innermost first
+	Callers []*parse.TemplateNode
 }
 
 func (e TypeCheckError) Error() string {
 	return e.Message
 }
 
+// UnwrapErrors returns the errors joined in err, eg. TypeCheckErrors returned by Check, or err itself.
+func UnwrapErrors(err error) []error {
+	if err == nil {
+		return nil
+	}
+	if u, ok := err.(interface{ Unwrap() []error }); ok {
+		return u.Unwrap()
+	}
+	return []error{err}
+}
+
 // {{/* @key value */}}
 var rxAnnotation = regexp.MustCompile(`^/\*\s*@(\w+)\s+(.*?)\s*\*/$`)
 
-func (s *Checker) loadFuncMap(fullType string) (map[string]*types.Signature, error) {
-	p := strings.LastIndex(fullType, ".")
-	pkgName, varName := fullType[:p], fullType[p+1:]
-	pkgs, err := packages.Load(&packages.Config{
-		Mode:  packages.NeedTypes | packages.NeedTypesInfo | packages.NeedSyntax,
-		Tests: true, // FIXME: this is only for testing purpose
-	}, pkgName)
+func (s *Checker) setDotType(fullType string) (types.Type, error) {
+	typ, err := s.lookupType(fullType)
 	if err != nil {
 		return nil, err
 	}
-	pkg := pkgs[0]
-	if pkg.Errors != nil {
-		return nil, fmt.Errorf("errors: %v", pkg.Errors)
-	}
-
-	varObj := pkg.Types.Scope().Lookup(varName)
-	if varObj == nil {
-		return nil, fmt.Errorf("cannot find %s.%s", pkgName, varName)
-	}
-	if types.TypeString(varObj.Type(), nil) != "text/template.FuncMap" {
-		return nil, fmt.Errorf("%s.%s is not text/template.FuncMap", pkgName, varName)
-	}
 
-	funcTypeMap := map[string]*types.Signature{}
-
-	for _, f := range pkg.Syntax {
-		path, _ := astutil.PathEnclosingInterval(f, varObj.Pos(), varObj.Pos())
-		if _, ok := path[0].(*ast.File); ok {
-			continue
-		}
-		for _, node := range path {
-			decl, ok := node.(*ast.GenDecl)
-			if !ok || decl.Tok != token.VAR {
-				continue
-			}
+	// TODO: compare dot with obj.Type()
+	s.setTopVarType(typ)
+	return typ, nil
+}
 
-			for _, spec := range decl.Specs {
-				valueSpec := spec.(*ast.ValueSpec)
-				for i := range valueSpec.Names {
-					if pkg.TypesInfo.Defs[valueSpec.Names[i]] != varObj {
-						continue
-					}
-					lit := valueSpec.Values[i].(*ast.CompositeLit)
-					for _, elt := range lit.Elts {
-						kv := elt.(*ast.KeyValueExpr)
-						key, err := strconv.Unquote(kv.Key.(*ast.BasicLit).Value)
-						if err != nil {
-							return nil, err
-						}
-						typ := pkg.TypesInfo.TypeOf(kv.Value)
-						funcTypeMap[key] = typ.(*types.Signature)
-					}
-				}
-			}
+// lookupType returns the type specified by fullType (path/to/pkg.Type), which may be prefixed by "*" for pointers.
+func (s *Checker) lookupType(fullType string) (types.Type, error) {
+	if name, ok := strings.CutPrefix(fullType, "*"); ok {
+		typ, err := s.lookupType(name)
+		if err != nil {
+			return nil, err
 		}
+		return types.NewPointer(typ), nil
 	}
 
-	return funcTypeMap, nil
-}
-
-func (s *Checker) setDotType(fullType string) (types.Type, error) {
-	p := strings.LastIndex(fullType, ".")
-	pkgName, typeName := fullType[:p], fullType[p+1:]
-
-	pkgs, err := packages.Load(&packages.Config{
-		Mode:  packages.NeedTypes | packages.NeedTypesInfo,
-		Tests: true, // FIXME: this is only for testing purpose
-	}, pkgName)
+	_, obj, err := s.lookup(fullType)
 	if err != nil {
-		return nil, fmt.Errorf("failed to load pakcage %q: %w", pkgName, err)
+		return nil, err
 	}
-	if pkgs[0].Errors != nil {
-		return nil, fmt.Errorf("failed to load package %q: %v", pkgName, pkgs[0].Errors)
+	if obj == nil {
+		return nil, fmt.Errorf("cannot load type %s", fullType)
 	}
-	for _, pkg := range pkgs {
-		obj := pkg.Types.Scope().Lookup(typeName)
-		if obj == nil {
+	return obj.Type(), nil
+}
+
+// templateParam returns the comment of @param annotation at the beginning of tree, which declares the type of dot of the template.
+// typ is nil if the type cannot be loaded.
+func (s *Checker) templateParam(tree *parse.Tree) (comment *parse.CommentNode, typ types.Type) {
+	if tree.Root == nil {
+		return nil, nil
+	}
+	for _, node := range tree.Root.Nodes {
+		if text, ok := node.(*parse.TextNode); ok && len(bytes.TrimSpace(text.Text)) == 0 {
 			continue
 		}
-		// TODO: compare dot with obj.Type()
-		s.setTopVarType(obj.Type())
-		return obj.Type(), nil
+		comment, ok := node.(*parse.CommentNode)
+		if !ok {
+			return nil, nil
+		}
+		m := rxAnnotation.FindStringSubmatch(comment.Text)
+		if m == nil || m[1] != "param" {
+			return nil, nil
+		}
+		// errors are reported by walk
+		typ, _ := s.lookupType(m[2])
+		return comment, typ
 	}
-	return nil, fmt.Errorf("cannot load type %s.%s", pkgName, typeName)
+	return nil, nil
 }
 
 // walk walks node.
@@ -178,14 +216,25 @@ func (s *Checker) walk(dot types.Type, node parse.Node) types.Type {
 				if err != nil {
 					s.errorf(node, "@type %s: %s", value, err)
 				}
+				s.record(node, typ, nil)
 				return typ
+			} else if key == "param" {
+				if node != s.param {
+					s.errorf(node, "@param must be at the beginning of a template")
+				} else if _, err := s.lookupType(value); err != nil {
+					s.errorf(node, "@param %s: %s", value, err)
+				}
 			} else if key == "debug" && value == "show ." {
 				s.debugf(node, "dot: %v", dot)
 			}
 		}
 
 	case *parse.ActionNode:
-		s.checkPipeline(dot, node.Pipe)
+		typ := s.checkPipeline(dot, node.Pipe)
+		// actions assigning variables output nothing
+		if s.HTML && len(node.Pipe.Decl) == 0 {
+			s.checkEscaping(node, typ)
+		}
 
 	case *parse.BreakNode, *parse.ContinueNode:
 		return dot
@@ -197,6 +246,7 @@ func (s *Checker) walk(dot types.Type, node parse.Node) types.Type {
 		if node == nil {
 			return dot
 		}
+		s.record(node, dot, nil)
 		for _, node := range node.Nodes {
 			dot = s.walk(dot, node)
 		}
@@ -211,6 +261,10 @@ func (s *Checker) walk(dot types.Type, node parse.Node) types.Type {
 		s.walkIfOrWith(parse.NodeWith, dot, node.Pipe, node.List, node.ElseList)
 
 	case *parse.TextNode:
+		s.record(node, dot, nil)
+		if s.HTML {
+			s.htmlCtx = s.htmlCtx.scan(string(node.Text))
+		}
 
 	default:
 		s.TODO(node, "walk: not implemented: %s (%T)", node, node)
@@ -220,33 +274,45 @@ func (s *Checker) walk(dot types.Type, node parse.Node) types.Type {
 }
 
 func (s *Checker) walkIfOrWith(nodeType parse.NodeType, dot types.Type, pipe *parse.PipeNode, list, elseList *parse.ListNode) {
+	// variables declared in the pipeline are visible until {{end}}, and those in the list are visible until {{else}}
+	mark := len(s.vars)
+
+	typ := s.checkPipeline(dot, pipe)
+	pipeMark := len(s.vars)
+
+	// both branches start in the same context, and html/template requires them to end in the same context
+	htmlCtx := s.htmlCtx
+
 	switch nodeType {
 	case parse.NodeWith:
-		newDot := s.checkPipeline(dot, pipe)
-		s.walk(newDot, list)
+		s.walk(nonNilType(typ), list)
+		htmlCtx, s.htmlCtx = s.htmlCtx, htmlCtx
+		s.vars = s.vars[:pipeMark]
 		s.walk(dot, elseList)
 	case parse.NodeIf:
 		s.walk(dot, list)
+		htmlCtx, s.htmlCtx = s.htmlCtx, htmlCtx
+		s.vars = s.vars[:pipeMark]
 		s.walk(dot, elseList)
 	default:
 		panic("unreachable")
 	}
+
+	s.htmlCtx = s.joinHTMLContexts(pipe, nodeType, htmlCtx, s.htmlCtx)
+	s.vars = s.vars[:mark]
 }
 
 func (s *Checker) walkRange(dot types.Type, r *parse.RangeNode) {
-	if dot == nil {
-		return
-	}
-
 	mark := len(s.vars)
+	defer func() { s.vars = s.vars[:mark] }()
 
-	typ := peelType(s.checkPipeline(dot, r.Pipe))
-	if typ == nil {
-		return
-	}
+	pipeType := s.checkPipeline(dot, r.Pipe)
+	typ := peelType(pipeType)
 
 	var keyType, elemType types.Type
 	switch typ := typ.(type) {
+	case nil:
+		// the type of the pipeline is unknown; walk the lists with unknown elements
 	case *types.Slice:
 		keyType = types.Typ[types.UntypedInt]
 		elemType = typ.Elem()
@@ -257,7 +323,56 @@ func (s *Checker) walkRange(dot types.Type, r *parse.RangeNode) {
 		keyType = typ.Key()
 		elemType = typ.Elem()
 	case *types.Chan:
+		if typ.Dir() == types.SendOnly {
+			s.errorf(r, "range over send-only channel %v", pipeType)
+			return
+		}
+		if len(r.Pipe.Decl) > 1 {
+			s.errorf(r, "can't use %v to iterate over more than one variable", pipeType)
+			return
+		}
 		elemType = typ.Elem()
+	case *types.Basic:
+		if typ.Info()&types.IsInteger == 0 {
+			s.errorf(r, "range can't iterate over %v, pipe: %s", typ, r.Pipe)
+			return
+		}
+		if len(r.Pipe.Decl) > 1 {
+			s.errorf(r, "can't use %v to iterate over more than one variable", pipeType)
+			return
+		}
+		// elements are of the same type as the integer, eg. 0, 1, ..., n-1
+		elemType = types.Default(pipeType)
+		for {
+			ptr, ok := elemType.(*types.Pointer)
+			if !ok {
+				break
+			}
+			elemType = ptr.Elem()
+		}
+	case *types.Signature:
+		params, ok := iteratorParams(typ)
+		if !ok {
+			s.errorf(r, "range can't iterate over %v, pipe: %s", pipeType, r.Pipe)
+			return
+		}
+		switch len(params) {
+		case 1:
+			// iter.Seq[V]
+			if len(r.Pipe.Decl) > 1 {
+				s.errorf(r, "can't use %v to iterate over more than one variable", pipeType)
+				return
+			}
+			elemType = params[0]
+		case 2:
+			// iter.Seq2[K, V]
+			if len(r.Pipe.Decl) > 1 {
+				keyType, elemType = params[0], params[1]
+			} else {
+				// with zero or one variable, the first value is used as the element
+				elemType = params[0]
+			}
+		}
 	default:
 		s.errorf(r, "range can't iterate over %v, pipe: %s", typ, r.Pipe)
 		return
@@ -285,9 +400,41 @@ func (s *Checker) walkRange(dot types.Type, r *parse.RangeNode) {
 		}
 	}
 
+	// the body may be executed no times, in which case {{else}} is executed in the same context
+	htmlCtx := s.htmlCtx
+
 	_ = s.walk(elemType, r.List)
 
-	s.vars = s.vars[:mark]
+	htmlCtx, s.htmlCtx = s.htmlCtx, htmlCtx
+	s.walk(dot, r.ElseList)
+	s.htmlCtx = s.joinHTMLContexts(r.Pipe, parse.NodeRange, htmlCtx, s.htmlCtx)
+}
+
+// iteratorParams returns the parameter types of the yield function
+// if sig is of the form of iter.Seq (func(yield func(V) bool)) or iter.Seq2 (func(yield func(K, V) bool)).
+func iteratorParams(sig *types.Signature) ([]types.Type, bool) {
+	if sig.Params().Len() != 1 || sig.Results().Len() != 0 {
+		return nil, false
+	}
+
+	yield, ok := sig.Params().At(0).Type().Underlying().(*types.Signature)
+	if !ok || yield.Variadic() || yield.Results().Len() != 1 {
+		return nil, false
+	}
+	if res, ok := yield.Results().At(0).Type().Underlying().(*types.Basic); !ok || res.Kind() != types.Bool {
+		return nil, false
+	}
+
+	n := yield.Params().Len()
+	if n != 1 && n != 2 {
+		return nil, false
+	}
+
+	params := make([]types.Type, n)
+	for i := range params {
+		params[i] = yield.Params().At(i).Type()
+	}
+	return params, true
 }
 
 func (s *Checker) walkTemplate(dot types.Type, t *parse.TemplateNode) {
@@ -301,18 +448,132 @@ func (s *Checker) walkTemplate(dot types.Type, t *parse.TemplateNode) {
 		return
 	}
 
-	if _, ok := s.visited[tree]; ok {
+	dot = s.checkPipeline(dot, t.Pipe)
+	s.recordCall(t, dot)
+
+	// the base definition of a {{block}} and the ones overriding it are all checked,
+	// as either of them is executed depending on the files parsed.
+	// Other templates defined multiple times are checked by the last definition as text/template executes.
+	variants := s.variants[t.Name]
+	if !s.blocks[t.Name] || len(variants) == 0 {
+		variants = []*parse.Tree{tree}
+	}
+	var base *templateCheck
+	htmlCtx := s.htmlCtx
+	for i, variant := range variants {
+		s.htmlCtx = htmlCtx
+		check := s.walkTemplateTree(dot, t, variant)
+		if i == 0 {
+			base = check
+		} else if base != nil && check != nil {
+			s.checkOverride(t, variants[0], base, variant, check)
+		}
+	}
+	if base != nil {
+		s.htmlCtx = base.htmlEnd
+	}
+}
+
+// walkTemplateTree walks tree invoked by t with data of dot.
+// It returns the check of tree, or nil if tree is being checked.
+func (s *Checker) walkTemplateTree(dot types.Type, t *parse.TemplateNode, tree *parse.Tree) *templateCheck {
+	// templates with @param are checked once with the type declared
+	param, paramType := s.templateParam(tree)
+	if param != nil {
+		if !assignableData(dot, paramType) {
+			s.errorf(t, "template %q: wrong type for data: expected %s; got %s", t.Name, paramType, dot)
+		}
+		dot = paramType
+	}
+
+	// templates are checked once per type of dot, and per context with HTML as html/template escapes them
+	var check *templateCheck
+	for _, c := range s.visited[tree] {
+		if c.htmlCtx != s.htmlCtx {
+			continue
+		}
+		if c.dot == nil && dot == nil || c.dot != nil && dot != nil && types.Identical(c.dot, dot) {
+			check = c
+			break
+		}
+	}
+	if check == nil {
+		check = &templateCheck{dot: dot, param: param != nil, htmlCtx: s.htmlCtx}
+		s.visited[tree] = append(s.visited[tree], check)
+
+		newState := *s
+		newState.vars = []variable{{"$", dot}}
+		newState.param = param
+		newState.errors = nil
+		newState.walk(dot, tree.Root)
+		check.errors = newState.errors
+		check.htmlEnd = newState.htmlCtx
+		check.done = true
+	} else if !check.done {
+		// recursive invocation
+		return nil
+	}
+	check.calls = append(check.calls, t)
+	s.htmlCtx = check.htmlEnd
+
+	for _, err := range check.errors {
+		if tcErr, ok := err.(TypeCheckError); ok {
+			tcErr.Callers = append(tcErr.Callers[:len(tcErr.Callers):len(tcErr.Callers)], t)
+			err = tcErr
+		}
+		s.errors = append(s.errors, err)
+	}
+
+	return check
+}
+
+// checkOverride reports override, a definition overriding base, expecting a different type of data from base.
+// Types declared by @param are compared, otherwise the errors of override with the data base is valid for are noted.
+func (s *Checker) checkOverride(t *parse.TemplateNode, base *parse.Tree, baseCheck *templateCheck, override *parse.Tree, check *templateCheck) {
+	if check.overrideChecked {
 		return
 	}
+	check.overrideChecked = true
 
-	s.visited[tree] = false
-	dot = s.checkPipeline(dot, t.Pipe)
-	newState := *s
-	newState.vars = []variable{{"$", dot}}
-	newState.errors = nil
-	newState.walk(dot, tree.Root)
-	s.errors = append(s.errors, newState.errors...)
-	s.visited[tree] = true
+	baseParam, baseType := s.templateParam(base)
+	param, typ := s.templateParam(override)
+	if baseParam != nil && param != nil {
+		if baseType != nil && typ != nil && !types.Identical(baseType, typ) {
+			s.errorf(param, "template %q expects %s, while the base definition expects %s", t.Name, typ, baseType)
+		}
+		return
+	}
+
+	if len(baseCheck.errors) == 0 && len(check.errors) > 0 && check.dot != nil {
+		// the errors of override are reported once, noting the type of the base definition
+		note := fmt.Sprintf(" (template %q overrides the base definition expecting %s)", t.Name, baseCheck.dot)
+		// the errors of override were just added for t by walkTemplateTree
+		reported := s.errors[len(s.errors)-len(check.errors):]
+		for _, errs := range [][]error{check.errors, reported} {
+			for i, err := range errs {
+				if tcErr, ok := err.(TypeCheckError); ok {
+					tcErr.Message += note
+					errs[i] = tcErr
+				}
+			}
+		}
+	}
+}
+
+// assignableData reports whether data of typ can be passed to a template declaring param by @param.
+// Unknown types and interfaces are assumed to be assignable, since text/template looks into the dynamic values of interfaces.
+// Pointers to param are also assignable, since text/template indirects them to evaluate fields.
+func assignableData(typ, param types.Type) bool {
+	if typ == nil || param == nil {
+		return true
+	}
+	if _, ok := typ.Underlying().(*types.Interface); ok {
+		return true
+	}
+	if ptr, ok := typ.Underlying().(*types.Pointer); ok && types.AssignableTo(ptr.Elem(), param) {
+		return true
+	}
+	return types.AssignableTo(typ, param)
 }
 
 func (s *Checker) push(name string, typ types.Type) {
@@ -334,8 +595,16 @@ func (s *Checker) checkPipeline(dot types.Type, pipe *parse.PipeNode) (final typ
 	if pipe == nil {
 		return
 	}
+	defer func() { s.record(pipe, dot, final) }()
 
-	for _, cmd := range pipe.Cmds {
+	for i, cmd := range pipe.Cmds {
+		if i > 0 && final == nil {
+			// the type of the preceding command is unknown; check the arguments only, as the call cannot be checked
+			for _, arg := range cmd.Args[1:] {
+				s.checkArg(dot, arg)
+			}
+			continue
+		}
 		final = s.checkCommand(dot, cmd, final)
 	}
 
@@ -350,8 +619,15 @@ func (s *Checker) checkPipeline(dot types.Type, pipe *parse.PipeNode) (final typ
 	return
 }
 
-// ref. text/template.state.evalCommand()
 func (s *Checker) checkCommand(dot types.Type, cmd *parse.CommandNode, final types.Type) types.Type {
+	typ := s.checkCommandType(dot, cmd, final)
+	s.record(cmd, dot, typ)
+	s.record(cmd.Args[0], dot, typ)
+	return typ
+}
+
+// ref. text/template.state.evalCommand()
+func (s *Checker) checkCommandType(dot types.Type, cmd *parse.CommandNode, final types.Type) types.Type {
 	firstWord := cmd.Args[0]
 	switch n := firstWord.(type) {
 	case *parse.FieldNode:
@@ -368,13 +644,13 @@ func (s *Checker) checkCommand(dot types.Type, cmd *parse.CommandNode, final typ
 
 	// TODO: notAFunction
 
-	switch firstWord.(type) {
+	switch word := firstWord.(type) {
 	case *parse.DotNode:
 		return dot
 	case *parse.StringNode:
 		return types.Typ[types.String]
 	case *parse.NumberNode:
-		return types.Typ[types.UntypedInt]
+		return numberType(word)
 	case *parse.NilNode:
 		return types.Typ[types.UntypedNil]
 	}
@@ -418,11 +694,16 @@ func (s *Checker) checkVariableNode(dot types.Type, variable *parse.VariableNode
 
 func (s *Checker) checkFieldChain(dot, receiver types.Type, node parse.Node, ident []string, args []parse.Node, final types.Type) types.Type {
 	n := len(ident)
+	objs := make([]types.Object, n)
+	defer func() { s.recordObjects(node, objs) }()
+
 	for i := 0; i < n-1; i++ {
-		receiver = s.checkField(dot, ident[i], node, nil, nil, receiver)
+		receiver, objs[i] = s.checkField(dot, ident[i], node, nil, nil, receiver)
 	}
 
-	return s.checkField(dot, ident[n-1], node, args, final, receiver)
+	typ, obj := s.checkField(dot, ident[n-1], node, args, final, receiver)
+	objs[n-1] = obj
+	return typ
 }
 
 func (s *Checker) checkFunction(dot types.Type, node *parse.IdentifierNode, cmd parse.Node, args []parse.Node, final types.Type) types.Type {
@@ -442,6 +723,10 @@ func (s *Checker) checkFunction(dot types.Type, node *parse.IdentifierNode, cmd
 		argTypes = append(argTypes, final)
 	}
 
+	if check, ok := s.Funcs[name]; ok {
+		return s.checkFuncChecker(cmd, name, check, dot, args, argTypes)
+	}
+
 	if checkBuiltin, ok := builtinFuncs[name]; ok {
 		if checkBuiltin == nil {
 			s.TODO(cmd, "checkFunction: builtin %q", name)
@@ -454,11 +739,41 @@ func (s *Checker) checkFunction(dot types.Type, node *parse.IdentifierNode, cmd
 			return nil
 		}
 
+		if name == "printf" && len(args) > 1 {
+			if format, ok := args[1].(*parse.StringNode); ok {
+				if err := checkPrintf(format.Text, argTypes[1:]); err != nil {
+					s.errorf(cmd, "function %s: %s", name, err)
+					return nil
+				}
+			}
+		}
+
 		return typ
 	}
 
 	if fun, ok := s.funcMap[name]; ok {
-		return fun.Results().At(0).Type()
+		if fun == nil {
+			// imported without the signature, see Importer
+			return nil
+		}
+		if err := checkArgs(fun, argTypes); err != nil {
+			s.errorf(cmd, "function %s: %s", name, err)
+			return nil
+		}
+
+		typ, err := resultType(fun)
+		if err != nil {
+			s.errorf(cmd, "function %s: %s", name, err)
+			return nil
+		}
+
+		s.checkTrustedConversion(cmd, name, args[1:], argTypes, typ)
+
+		return typ
+	}
+
+	if check, ok := s.catalog[name]; ok {
+		return s.checkFuncChecker(cmd, name, check, dot, args, argTypes)
 	}
 
 	if s.AllowUndefinedFuncs {
@@ -469,6 +784,19 @@ func (s *Checker) checkFunction(dot types.Type, node *parse.IdentifierNode, cmd
 	return nil
 }
 
+// checkFuncChecker checks a call of function name by check.
+func (s *Checker) checkFuncChecker(cmd parse.Node, name string, check FuncChecker, dot types.Type, args []parse.Node, argTypes []types.Type) types.Type {
+	typ, err := check(dot, argTypes)
+	if err != nil {
+		s.errorf(cmd, "function %s: %s", name, err)
+		return nil
+	}
+
+	s.checkTrustedConversion(cmd, name, args[1:], argTypes, typ)
+
+	return typ
+}
+
 func (s *Checker) checkCall(dot types.Type, fun *types.Func, node parse.Node, name string, args []parse.Node, final types.Type) types.Type {
 	argTypes := []types.Type{}
 
@@ -485,24 +813,108 @@ func (s *Checker) checkCall(dot types.Type, fun *types.Func, node parse.Node, na
 		}
 	}
 
-	// TODO: check arg
-	_ = argTypes
+	sig := fun.Type().(*types.Signature)
+	if err := checkArgs(sig, argTypes); err != nil {
+		s.errorf(node, "function %s: %s", name, err)
+		return nil
+	}
 
-	results := fun.Type().(*types.Signature).Results()
+	typ, err := resultType(sig)
+	if err != nil {
+		s.errorf(node, "function %s: %s", name, err)
+		return nil
+	}
+
+	if len(args) > 0 {
+		s.checkTrustedConversion(node, name, args[1:], argTypes, typ)
+	}
+
+	return typ
+}
+
+// checkArgs checks that args can be passed to a function of signature sig.
+// ref. text/template.state.evalCall()
+func checkArgs(sig *types.Signature, args []types.Type) error {
+	params := sig.Params()
+	numIn := params.Len()
+	numFixed := numIn
+	if sig.Variadic() {
+		numFixed--
+		if len(args) < numFixed {
+			return fmt.Errorf("wrong number of args: want at least %d got %d", numFixed, len(args))
+		}
+	} else if len(args) != numIn {
+		return fmt.Errorf("wrong number of args: want %d got %d", numIn, len(args))
+	}
+
+	for i, arg := range args {
+		var param types.Type
+		if i < numFixed {
+			param = params.At(i).Type()
+		} else {
+			param = params.At(numIn - 1).Type().(*types.Slice).Elem()
+		}
+		if !assignableArg(arg, param) {
+			return fmt.Errorf("wrong type for argument %d: expected %s; got %s", i+1, param, arg)
+		}
+	}
+
+	return nil
+}
+
+// assignableArg reports whether a value of type arg can be passed as a parameter of type param.
+// Like text/template does at runtime, pointers are dereferenced or taken if needed,
+// and interface values are allowed if their dynamic type may fit.
+// ref. text/template.state.validateType()
+func assignableArg(arg, param types.Type) bool {
+	if types.AssignableTo(arg, param) {
+		return true
+	}
+	if types.IsInterface(arg) && types.AssignableTo(param, arg) {
+		return true
+	}
+	if ptr, ok := arg.(*types.Pointer); ok && types.AssignableTo(ptr.Elem(), param) {
+		return true
+	}
+	if types.AssignableTo(types.NewPointer(arg), param) {
+		return true
+	}
+	return false
+}
+
+// resultType returns the type of the value that a call to a function of signature sig evaluates to.
+func resultType(sig *types.Signature) (types.Type, error) {
+	results := sig.Results()
 	switch results.Len() {
 	case 1:
-		return results.At(0).Type()
+		return results.At(0).Type(), nil
 
 	case 2:
 		if results.At(1).Type() != types.Universe.Lookup("error").Type() {
-			s.errorf(node, "function %s: second return value must be error", name)
-			return nil
+			return nil, fmt.Errorf("second return value must be error")
 		}
-		return results.At(0).Type()
+		return results.At(0).Type(), nil
 
 	default:
-		s.errorf(node, "function %s: must return 1 or 2 values", name)
-		return nil
+		return nil, fmt.Errorf("must return 1 or 2 values")
+	}
+}
+
+// numberType returns the type of a number constant, as text/template evaluates it.
+// ref. text/template.state.idealConstant()
+func numberType(n *parse.NumberNode) types.Type {
+	isHexInt := len(n.Text) > 2 && n.Text[0] == '0' && (n.Text[1] == 'x' || n.Text[1] == 'X') && !strings.ContainsAny(n.Text, "pP")
+	isRuneInt := len(n.Text) > 0 && n.Text[0] == '\''
+
+	switch {
+	case n.IsComplex:
+		return types.Typ[types.UntypedComplex]
+	case n.IsFloat && !isHexInt && !isRuneInt && strings.ContainsAny(n.Text, ".eEpP"):
+		return types.Typ[types.UntypedFloat]
+	case isRuneInt:
+		return types.Typ[types.UntypedRune]
+	default:
+		return types.Typ[types.UntypedInt]
 	}
 }
 
@@ -529,9 +941,10 @@ func lookupMethod(typ types.Type, name string) *types.Func {
 	return nil
 }
 
-func (s *Checker) checkField(dot types.Type, fieldName string, node parse.Node, args []parse.Node, final types.Type, receiver types.Type) types.Type {
+// checkField returns the type of the field or the method fieldName of receiver, and the object of it if any.
+func (s *Checker) checkField(dot types.Type, fieldName string, node parse.Node, args []parse.Node, final types.Type, receiver types.Type) (types.Type, types.Object) {
 	if receiver == nil {
-		return nil
+		return nil, nil
 	}
 
 	// TODO: check method
@@ -542,32 +955,43 @@ func (s *Checker) checkField(dot types.Type, fieldName string, node parse.Node,
 	obj, _, _ := types.LookupFieldOrMethod(receiver, false, nil, fieldName)
 	if obj != nil {
 		if meth, ok := obj.(*types.Func); ok {
-			return s.checkCall(dot, meth, node, fieldName, args, final)
+			return s.checkCall(dot, meth, node, fieldName, args, final), meth
 		} else {
 			if hasArgs {
 				s.errorf(node, "field %q does not take any arguments", fieldName)
 			}
-			return obj.Type()
+			return obj.Type(), obj
 		}
 	}
 
 	if meth := lookupMethod(receiver, fieldName); meth != nil {
-		return s.checkCall(dot, meth, node, fieldName, args, final)
+		return s.checkCall(dot, meth, node, fieldName, args, final), meth
 	}
 
 	receiver = peelType(receiver)
 
-	switch receiver := receiver.(type) {
+	switch receiver := receiver.Underlying().(type) {
 	case *types.Map:
-		return valueTypeOf(receiver)
+		return valueTypeOf(receiver), nil
+	case *types.Interface:
+		// text/template looks into the dynamic value, which can be anything.
+		if receiver.Empty() {
+			return nil, nil
+		}
 	}
 
 	s.errorf(node, "can't evaluate field %s in type %v", fieldName, origReceiver)
 
-	return nil
+	return nil, nil
 }
 
 func (s *Checker) checkArg(dot types.Type, n parse.Node) types.Type {
+	typ := s.checkArgType(dot, n)
+	s.record(n, dot, typ)
+	return typ
+}
+
+func (s *Checker) checkArgType(dot types.Type, n parse.Node) types.Type {
 	// TODO
 	switch arg := n.(type) {
 	case *parse.DotNode:
@@ -581,14 +1005,14 @@ func (s *Checker) checkArg(dot types.Type, n parse.Node) types.Type {
 	case *parse.PipeNode:
 		return s.checkPipeline(dot, arg)
 	case *parse.IdentifierNode:
-		return s.checkFunction(dot, arg, arg, nil, nil)
+		return s.checkFunction(dot, arg, arg, []parse.Node{arg}, nil)
 	case *parse.ChainNode:
 		return s.checkChainNode(dot, arg, nil, nil)
 
 	case *parse.StringNode:
 		return types.Typ[types.String]
 	case *parse.NumberNode:
-		return types.Typ[types.UntypedInt]
+		return numberType(arg)
 	case *parse.BoolNode:
 		return types.Typ[types.UntypedBool]
 	}
@@ -621,6 +1045,28 @@ func (s *Checker) errorf(node parse.Node, format string, args ...interface{}) {
 	})
 }
 
+// nonNilType returns the type of a value of typ that is known to be non-nil, as the body of {{with}}.
+// Pointers are dereferenced, and an empty interface results in an unknown type (nil)
+// since text/template looks into its dynamic value.
+func nonNilType(typ types.Type) types.Type {
+	for {
+		if typ == nil {
+			return nil
+		}
+		switch t := typ.Underlying().(type) {
+		case *types.Pointer:
+			typ = t.Elem()
+		case *types.Interface:
+			if t.Empty() {
+				return nil
+			}
+			return typ
+		default:
+			return typ
+		}
+	}
+}
+
 func peelType(typ types.Type) types.Type {
 	for {
 		switch t := typ.(type) {
@@ -641,12 +1087,56 @@ func (s *Checker) diagContext(node parse.Node) (loc, context string) {
 func (s *Checker) FormatError(err error) string {
 	if te, ok := err.(TypeCheckError); ok {
 		loc, context := s.diagContext(te.Node)
-		return fmt.Sprintf("%s: in %s: %s", loc, context, te.Message)
+		msg := fmt.Sprintf("%s: in %s: %s", loc, context, te.Message)
+		for _, caller := range te.Callers {
+			loc, context := s.diagContext(caller)
+			msg += fmt.Sprintf("\n\t%s: called from %s", loc, context)
+		}
+		return msg
 	} else {
 		return err.Error()
 	}
 }
 
+// FileSet returns the file set of the Go packages loaded, by which positions of types.Object are resolved.
+func (s *Checker) FileSet() *token.FileSet {
+	if s.fset == nil {
+		s.fset = token.NewFileSet()
+	}
+	return s.fset
+}
+
+// Position returns the name of the source and the byte offset in it of the start of node, as parsed.
+func (s *Checker) Position(node parse.Node) (name string, offset int) {
+	loc, _ := s.diagContext(node)
+	// loc is in the form of name:line:col
+	for i := 0; i < 2; i++ {
+		if p := strings.LastIndex(loc, ":"); p >= 0 {
+			loc = loc[:p]
+		}
+	}
+
+	offset = int(node.Position())
+	// the parser positions chained fields at the second identifier, eg. ".Nope" of ".Meta.Nope"
+	switch node := node.(type) {
+	case *parse.FieldNode:
+		if len(node.Ident) > 1 {
+			offset -= len(node.Ident[0]) + 1
+		}
+	case *parse.VariableNode:
+		if len(node.Ident) > 1 {
+			offset -= len(node.Ident[0])
+		}
+	case *parse.ChainNode:
+		// and chains at the first field
+		offset -= len(node.String())
+		for _, field := range node.Field {
+			offset += len(field) + 1
+		}
+	}
+	return loc, offset
+}
+
 func (s *Checker) ParseFile(filename string) error {
 	f, err := os.Open(filename)
 	if err != nil {
@@ -657,6 +1147,11 @@ func (s *Checker) ParseFile(filename string) error {
 }
 
 func (s *Checker) Parse(name string, r io.Reader) error {
+	return s.parse(name, name, r)
+}
+
+// parse parses a template named name from r. parseName is the name of the source used in diagnostics.
+func (s *Checker) parse(name, parseName string, r io.Reader) error {
 	tree := parse.New(name)
 	tree.Mode = parse.ParseComments | parse.SkipFuncCheck
 
@@ -666,23 +1161,82 @@ func (s *Checker) Parse(name string, r io.Reader) error {
 	}
 
 	treeSet := map[string]*parse.Tree{}
-	_, err = tree.Parse(string(content), "", "", treeSet)
+	source := string(content)
+	_, err = tree.Parse(source, s.LeftDelim, s.RightDelim, treeSet)
 	if err != nil {
 		return err
 	}
+	for _, tree := range treeSet {
+		tree.ParseName = parseName
+	}
 
 	if s.treeSet == nil {
 		s.treeSet = map[string]*parse.Tree{}
+		s.variants = map[string][]*parse.Tree{}
+		s.blocks = map[string]bool{}
+	}
+	for _, tree := range treeSet {
+		inspect(tree.Root, func(node parse.Node) {
+			if t, ok := node.(*parse.TemplateNode); ok && s.isBlock(source, t) {
+				s.blocks[t.Name] = true
+			}
+		})
 	}
 	for name, tree := range treeSet {
+		empty := parse.IsEmptyTree(tree.Root)
+		// ref. text/template.(*Template).associate
+		if _, ok := s.treeSet[name]; ok && empty {
+			continue
+		}
 		s.treeSet[name] = tree
+		if !empty {
+			s.variants[name] = append(s.variants[name], tree)
+		}
 	}
 
 	return nil
 }
 
+// isBlock reports whether t parsed from source is a {{block}} action rather than {{template}}.
+// Both are parsed to TemplateNode positioned at the name.
+func (s *Checker) isBlock(source string, t *parse.TemplateNode) bool {
+	leftDelim := s.LeftDelim
+	if leftDelim == "" {
+		leftDelim = "{{"
+	}
+	before := source[:t.Pos]
+	i := strings.LastIndex(before, leftDelim)
+	if i < 0 {
+		return false
+	}
+	keyword := strings.Fields(strings.TrimPrefix(before[i+len(leftDelim):], "-"))
+	return len(keyword) == 1 && keyword[0] == "block"
+}
+
+// trees returns the trees parsed, including the definitions overridden.
+func (s *Checker) trees() []*parse.Tree {
+	var trees []*parse.Tree
+	seen := map[*parse.Tree]bool{}
+	for name, tree := range s.treeSet {
+		for _, t := range append([]*parse.Tree{tree}, s.variants[name]...) {
+			if !seen[t] {
+				seen[t] = true
+				trees = append(trees, t)
+			}
+		}
+	}
+	return trees
+}
+
 func (s *Checker) Check(entryPoint string) error {
-	s.visited = map[*parse.Tree]bool{}
+	return s.check(entryPoint, nil)
+}
+
+// check checks the template entryPoint.
+// If binding is non-nil, its type of dot is used instead of DotType.
+func (s *Checker) check(entryPoint string, binding *Binding) error {
+	s.visited = map[*parse.Tree][]*templateCheck{}
+	s.errors = nil
 
 	tree := s.treeSet[entryPoint]
 	if tree == nil {
@@ -692,24 +1246,66 @@ func (s *Checker) Check(entryPoint string) error {
 	s.vars = []variable{
 		{name: "$", typ: nil},
 	}
+	s.htmlCtx = htmlContext{}
 
-	if s.FuncMapVar != "" {
-		m, err := s.loadFuncMap(s.FuncMapVar)
-		if err != nil {
-			return err
+	// load the packages referred to at once, so that types from them are comparable
+	if err := s.loadPackages(s.referredPackages()...); err != nil {
+		return err
+	}
+
+	catalog, err := s.loadCatalogs()
+	if err != nil {
+		return err
+	}
+	s.catalog = catalog
+
+	s.funcMap = nil
+	if len(s.setFuncMaps) > 0 || s.FuncMapVar != "" {
+		s.funcMap = map[string]*types.Signature{}
+		for _, expr := range s.setFuncMaps {
+			m, err := s.loadFuncMapExpr(expr)
+			if err != nil {
+				return err
+			}
+			for key, fun := range m {
+				s.funcMap[key] = fun
+			}
+		}
+		for _, name := range s.funcMapVars() {
+			m, err := s.loadFuncMap(name)
+			if err != nil {
+				return err
+			}
+			for key, fun := range m {
+				s.funcMap[key] = fun
+			}
 		}
-		s.funcMap = m
 	}
 
 	var typ types.Type
-	if s.DotType != "" {
-		var err error
+	if binding != nil {
+		typ = binding.Dot
+		s.setTopVarType(typ)
+	} else if s.DotType != "" {
 		typ, err = s.setDotType(s.DotType)
 		if err != nil {
 			return err
 		}
 	}
+
+	// entry points may also be annotated with @param, eg. ones executed by ExecuteTemplate
+	param, paramType := s.templateParam(tree)
+	s.param = param
+	if param != nil {
+		if !assignableData(typ, paramType) {
+			s.errorf(param, "wrong type for data: expected %s; got %s", paramType, typ)
+		}
+		typ = paramType
+		s.setTopVarType(typ)
+	}
+
 	s.walk(typ, tree.Root)
+	s.checkCallers()
 
 	return errors.Join(s.errors...)
 }
@@ -2,10 +2,22 @@ package templatetypes
 
 import (
 	"bytes"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/constant"
+	"go/token"
+	"go/types"
+	htmltemplate "html/template"
+	"io"
+	"path/filepath"
 	"strings"
 	"testing"
 	"text/template"
+	"text/template/parse"
 
+	"golang.org/x/tools/go/packages"
 	"gotest.tools/v3/assert"
 )
 
@@ -16,6 +28,14 @@ type Dot1 struct {
 	Map   map[string]Dot1ContainedValue
 	Func1 func(n int, s string) FuncResult
 	Intf  Dot1InnerInterface
+	Ptr   *Dot1Inner
+	Func2 Dot1Func
+	Func3 func(ss ...string) (string, error)
+	Func4 func() (string, int)
+	FuncI func(n int64) int64
+	FuncF func(f float64) float64
+	FuncS func(s Dot1Inner) int
+	Any   any
 	Dot1Embedded
 }
 
@@ -23,8 +43,23 @@ func (Dot1) Method() string {
 	return "method"
 }
 
+func (Dot1) MethodWithArgs(n int, s string) string {
+	return strings.Repeat(s, n)
+}
+
+func (Dot1) VariadicMethod(sep string, elems ...string) string {
+	return strings.Join(elems, sep)
+}
+
+type Dot1Func func(n int) Dot1Inner
+
 type Dot1Inner struct {
 	InnerField int
+	Array      [3]int
+}
+
+func (d *Dot1Inner) PtrMethod(s string) Dot1Inner {
+	return Dot1Inner{InnerField: d.InnerField + len(s)}
 }
 
 type Dot1InnerInterface interface {
@@ -186,6 +221,74 @@ func TestCheck(t *testing.T) {
 {{template "subtemplate" .Inner}}`,
 			"can't evaluate field InvalidKey in type github.com/motemen/go-template-statictools/templatetypes.Dot1Inner",
 		},
+		{
+			"template called with different types", `
+{{define "subtemplate"}}{{.InnerField}}{{end}}
+{{/* @type github.com/motemen/go-template-statictools/templatetypes.Dot1 */}}
+{{template "subtemplate" .Inner}}
+{{template "subtemplate" .Ptr}}
+{{template "subtemplate" .}}`,
+			"can't evaluate field InnerField in type github.com/motemen/go-template-statictools/templatetypes.Dot1" +
+				` (template "subtemplate" is valid for data of type github.com/motemen/go-template-statictools/templatetypes.Dot1Inner passed by other callers)`,
+		},
+		{
+			"recursive template", `
+{{define "subtemplate"}}{{range .}}{{template "subtemplate" $.Slice}}{{end}}{{end}}
+{{/* @type github.com/motemen/go-template-statictools/templatetypes.Dot1 */}}
+{{template "subtemplate" .Slice}}`,
+			"can't evaluate field Slice in type []github.com/motemen/go-template-statictools/templatetypes.Dot1ContainedValue",
+		},
+		{
+			"template with @param", `
+{{define "subtemplate"}}
+  {{/* @param github.com/motemen/go-template-statictools/templatetypes.Dot1Inner */}}
+  {{.InnerField}}
+{{end}}
+{{/* @type github.com/motemen/go-template-statictools/templatetypes.Dot1 */}}
+{{template "subtemplate" .Inner}}
+{{template "subtemplate" .Any}}`,
+			"",
+		},
+		{
+			"template with @param, pointer", `
+{{define "subtemplate"}}{{/* @param github.com/motemen/go-template-statictools/templatetypes.Dot1Inner */}}{{.InnerField}}{{end}}
+{{/* @type github.com/motemen/go-template-statictools/templatetypes.Dot1 */}}
+{{template "subtemplate" .Ptr}}`,
+			"",
+		},
+		{
+			"template with @param, wrong type", `
+{{define "subtemplate"}}{{/* @param github.com/motemen/go-template-statictools/templatetypes.Dot1Inner */}}{{.InnerField}}{{end}}
+{{/* @type github.com/motemen/go-template-statictools/templatetypes.Dot1 */}}
+{{template "subtemplate" .Slice}}`,
+			`template "subtemplate": wrong type for data: expected github.com/motemen/go-template-statictools/templatetypes.Dot1Inner; got []github.com/motemen/go-template-statictools/templatetypes.Dot1ContainedValue`,
+		},
+		{
+			"template with @param of pointer", `
+{{define "subtemplate"}}{{/* @param *github.com/motemen/go-template-statictools/templatetypes.Dot1Inner */}}{{.InnerField}}{{end}}
+{{/* @type github.com/motemen/go-template-statictools/templatetypes.Dot1 */}}
+{{template "subtemplate" .Ptr}}
+{{template "subtemplate" .Inner}}`,
+			`template "subtemplate": wrong type for data: expected *github.com/motemen/go-template-statictools/templatetypes.Dot1Inner; got github.com/motemen/go-template-statictools/templatetypes.Dot1Inner`,
+		},
+		{
+			"template with @param, error in body", `
+{{define "subtemplate"}}{{/* @param github.com/motemen/go-template-statictools/templatetypes.Dot1Inner */}}{{.InvalidKey}}{{end}}
+{{template "subtemplate"}}`,
+			"can't evaluate field InvalidKey in type github.com/motemen/go-template-statictools/templatetypes.Dot1Inner",
+		},
+		{
+			"misplaced @param", `
+{{define "subtemplate"}}{{.}}{{/* @param github.com/motemen/go-template-statictools/templatetypes.Dot1Inner */}}{{end}}
+{{template "subtemplate"}}`,
+			"@param must be at the beginning of a template",
+		},
+		{
+			"pipeline after unknown type", `
+{{/* @type github.com/motemen/go-template-statictools/templatetypes.Dot1 */}}
+{{with .Any}}{{. | printf "%v %v" $.NoSuchField}}{{end}}`,
+			"can't evaluate field NoSuchField in type github.com/motemen/go-template-statictools/templatetypes.Dot1",
+		},
 		{
 			"with else", `
 {{/* @type github.com/motemen/go-template-statictools/templatetypes.Dot1 */}}
@@ -196,6 +299,140 @@ func TestCheck(t *testing.T) {
 {{end}}`,
 			"can't evaluate field InnerField in type github.com/motemen/go-template-statictools/templatetypes.Dot1",
 		},
+		{
+			"if with variable", `
+{{/* @type github.com/motemen/go-template-statictools/templatetypes.Dot1 */}}
+{{if $x := .Ptr}}
+  {{$x.InnerField}}
+  {{.Foo}}
+{{else}}
+  {{$x}}
+{{end}}`,
+			"",
+		},
+		{
+			"if with variable, invalid", `
+{{/* @type github.com/motemen/go-template-statictools/templatetypes.Dot1 */}}
+{{if $x := .Ptr}}{{$x.Invalid}}{{end}}`,
+			"can't evaluate field Invalid in type *github.com/motemen/go-template-statictools/templatetypes.Dot1Inner",
+		},
+		{
+			"if, invalid pipeline", `
+{{/* @type github.com/motemen/go-template-statictools/templatetypes.Dot1 */}}
+{{if .Invalid}}{{end}}`,
+			"can't evaluate field Invalid in type github.com/motemen/go-template-statictools/templatetypes.Dot1",
+		},
+		{
+			"with variable", `
+{{/* @type github.com/motemen/go-template-statictools/templatetypes.Dot1 */}}
+{{with $v := .Ptr}}
+  {{$v.InnerField}}
+  {{.InnerField}}
+{{else}}
+  {{$v}}
+  {{.Foo}}
+{{end}}`,
+			"",
+		},
+		{
+			"with variable, invalid", `
+{{/* @type github.com/motemen/go-template-statictools/templatetypes.Dot1 */}}
+{{with $v := .Ptr}}{{$v.Foo}}{{end}}`,
+			"can't evaluate field Foo in type *github.com/motemen/go-template-statictools/templatetypes.Dot1Inner",
+		},
+		{
+			"with pointer", `
+{{/* @type github.com/motemen/go-template-statictools/templatetypes.Dot1 */}}
+{{with .Ptr}}{{.Foo}}{{end}}`,
+			"can't evaluate field Foo in type github.com/motemen/go-template-statictools/templatetypes.Dot1Inner",
+		},
+		{
+			"with empty interface", `
+{{/* @type github.com/motemen/go-template-statictools/templatetypes.Dot1 */}}
+{{with .Any}}{{.InnerField}}{{end}}`,
+			"",
+		},
+		{
+			"variable scope of if", `
+{{/* @type github.com/motemen/go-template-statictools/templatetypes.Dot1 */}}
+{{$x := .Foo}}
+{{if $x := .Inner}}{{$x.InnerField}}{{end}}
+{{len $x}}`,
+			"",
+		},
+		{
+			"variable scope of with", `
+{{/* @type github.com/motemen/go-template-statictools/templatetypes.Dot1 */}}
+{{$x := .Foo}}
+{{with $x := .Inner}}{{$x.InnerField}}{{$y := .InnerField}}{{$y}}{{end}}
+{{len $x}}`,
+			"",
+		},
+		{
+			"variable scope of else", `
+{{/* @type github.com/motemen/go-template-statictools/templatetypes.Dot1 */}}
+{{$x := .Foo}}
+{{if $x := .Inner}}{{$y := .Foo}}{{else}}{{$x.InnerField}}{{end}}
+{{with $x := .Ptr}}{{$x.InnerField}}{{else}}{{len $x}}{{end}}`,
+			"function len: invalid argument type *github.com/motemen/go-template-statictools/templatetypes.Dot1Inner",
+		},
+		{
+			"else if", `
+{{/* @type github.com/motemen/go-template-statictools/templatetypes.Dot1 */}}
+{{if .Ptr}}
+  {{.Foo}}
+{{else if $x := .Inner}}
+  {{$x.InnerField}}
+{{else}}
+  {{.Foo}}
+{{end}}`,
+			"",
+		},
+		{
+			"else with", `
+{{/* @type github.com/motemen/go-template-statictools/templatetypes.Dot1 */}}
+{{with .Ptr}}
+  {{.InnerField}}
+{{else with .Slice}}
+  {{range .}}{{.Value}}{{end}}
+{{else with $v := .Inner}}
+  {{.InnerField}}{{$v.InnerField}}
+{{else}}
+  {{.Foo}}
+{{end}}`,
+			"",
+		},
+		{
+			"else with, invalid", `
+{{/* @type github.com/motemen/go-template-statictools/templatetypes.Dot1 */}}
+{{with .Ptr}}
+  {{.InnerField}}
+{{else with .Slice}}
+  {{.InnerField}}
+{{end}}`,
+			"can't evaluate field InnerField in type []github.com/motemen/go-template-statictools/templatetypes.Dot1ContainedValue",
+		},
+		{
+			"break and continue", `
+{{/* @type github.com/motemen/go-template-statictools/templatetypes.Dot1 */}}
+{{range .Slice}}
+  {{if .Value}}{{continue}}{{end}}
+  {{with .Value}}{{break}}{{end}}
+{{else}}
+  {{.Foo}}
+{{end}}`,
+			"",
+		},
+		{
+			"range else, invalid", `
+{{/* @type github.com/motemen/go-template-statictools/templatetypes.Dot1 */}}
+{{range .Slice}}
+  {{.Value}}
+{{else}}
+  {{.Value}}
+{{end}}`,
+			"can't evaluate field Value in type github.com/motemen/go-template-statictools/templatetypes.Dot1",
+		},
 		{
 			"invalid arg of user-defined function", `
 {{/* @type github.com/motemen/go-template-statictools/templatetypes.Dot1 */}}
@@ -277,31 +514,381 @@ func TestCheck(t *testing.T) {
 {{$item.Value}}
 {{end}}`, "",
 		},
+		{
+			"method with args", `
+{{/* @type github.com/motemen/go-template-statictools/templatetypes.Dot1 */}}
+{{.MethodWithArgs 3 "x"}}
+{{"x" | .MethodWithArgs 3}}
+{{.VariadicMethod ","}}
+{{.VariadicMethod "," "a" .Foo}}
+{{(.Ptr.PtrMethod "x").InnerField}}
+{{.Ptr.PtrMethod "x" | printf "%v"}}`, "",
+		},
+		{
+			"method with too few args", `
+{{/* @type github.com/motemen/go-template-statictools/templatetypes.Dot1 */}}
+{{.MethodWithArgs 3}}`,
+			"function MethodWithArgs: wrong number of args: want 2 got 1",
+		},
+		{
+			"method with too many args", `
+{{/* @type github.com/motemen/go-template-statictools/templatetypes.Dot1 */}}
+{{.Method 1 2 3}}`,
+			"function Method: wrong number of args: want 0 got 3",
+		},
+		{
+			"method with too many args from pipeline", `
+{{/* @type github.com/motemen/go-template-statictools/templatetypes.Dot1 */}}
+{{"x" | .MethodWithArgs 3 "y"}}`,
+			"function MethodWithArgs: wrong number of args: want 2 got 3",
+		},
+		{
+			"method in chain with args missing", `
+{{/* @type github.com/motemen/go-template-statictools/templatetypes.Dot1 */}}
+{{.Ptr.PtrMethod.InnerField}}`,
+			"function PtrMethod: wrong number of args: want 1 got 0",
+		},
+		{
+			"method with wrong arg type", `
+{{/* @type github.com/motemen/go-template-statictools/templatetypes.Dot1 */}}
+{{.MethodWithArgs "x" 3}}`,
+			"function MethodWithArgs: wrong type for argument 1: expected int; got string",
+		},
+		{
+			"pointer method with wrong arg type", `
+{{/* @type github.com/motemen/go-template-statictools/templatetypes.Dot1 */}}
+{{.Ptr.PtrMethod .Inner}}`,
+			"function PtrMethod: wrong type for argument 1: expected string; got github.com/motemen/go-template-statictools/templatetypes.Dot1Inner",
+		},
+		{
+			"variadic method with wrong arg type", `
+{{/* @type github.com/motemen/go-template-statictools/templatetypes.Dot1 */}}
+{{.VariadicMethod "," "a" 1}}`,
+			"function VariadicMethod: wrong type for argument 3: expected string; got untyped int",
+		},
+		{
+			"method with wrong arg type from pipeline", `
+{{/* @type github.com/motemen/go-template-statictools/templatetypes.Dot1 */}}
+{{.Inner | .MethodWithArgs 3}}`,
+			"function MethodWithArgs: wrong type for argument 2: expected string; got github.com/motemen/go-template-statictools/templatetypes.Dot1Inner",
+		},
+		{
+			"builtin call with arguments as evaluated", `
+{{/* @type github.com/motemen/go-template-statictools/templatetypes.Dot1 */}}
+{{call .FuncI .Inner.InnerField}}
+{{call .FuncI 3}}
+{{call .FuncF 1.5}}
+{{call .FuncS .Inner}}
+{{call .FuncS .Any}}`,
+			"",
+		},
+		{
+			"builtin call with pointer", `
+{{/* @type github.com/motemen/go-template-statictools/templatetypes.Dot1 */}}
+{{call .FuncS .Ptr}}`,
+			"function call: wrong type for argument 1: expected github.com/motemen/go-template-statictools/templatetypes.Dot1Inner; got *github.com/motemen/go-template-statictools/templatetypes.Dot1Inner",
+		},
+		{
+			"builtin call with integer constant for float", `
+{{/* @type github.com/motemen/go-template-statictools/templatetypes.Dot1 */}}
+{{$x := 1}}
+{{call .FuncF $x}}`,
+			"function call: wrong type for argument 1: expected float64; got int",
+		},
+		{
+			"builtin call with nil", `
+{{/* @type github.com/motemen/go-template-statictools/templatetypes.Dot1 */}}
+{{call .Func2 nil}}`,
+			"function call: argument 1 is nil; should be of type int",
+		},
 		{
 			"builtin call", `
 {{/* @type github.com/motemen/go-template-statictools/templatetypes.Dot1 */}}
 {{(call .Func1 3 "foo").ResultField}}`,
 			"",
 		},
+		{
+			"builtin comparisons", `
+{{/* @type github.com/motemen/go-template-statictools/templatetypes.Dot1 */}}
+{{eq .Foo "foo"}}
+{{eq .Foo "a" "b" "foo"}}
+{{ne .Inner.InnerField 3}}
+{{lt .Inner.InnerField 100}}
+{{le 1.5 2.5}}
+{{gt .Foo "a"}}
+{{ge (len .Slice) 1}}
+{{eq .Inner .Inner}}
+{{eq true (not false)}}
+{{if eq .Foo "foo"}}{{end}}`,
+			"",
+		},
+		{
+			"builtin eq with incompatible types", `
+{{/* @type github.com/motemen/go-template-statictools/templatetypes.Dot1 */}}
+{{eq .Inner.InnerField "3"}}`,
+			"function eq: incompatible types for comparison: int and string",
+		},
+		{
+			"builtin eq with incompatible types in rest args", `
+{{/* @type github.com/motemen/go-template-statictools/templatetypes.Dot1 */}}
+{{eq .Foo "a" 1}}`,
+			"function eq: incompatible types for comparison: string and untyped int",
+		},
+		{
+			"builtin eq with missing argument", `
+{{/* @type github.com/motemen/go-template-statictools/templatetypes.Dot1 */}}
+{{eq .Foo}}`,
+			"function eq: missing argument for comparison",
+		},
+		{
+			"builtin eq with non-comparable types", `
+{{/* @type github.com/motemen/go-template-statictools/templatetypes.Dot1 */}}
+{{eq .Slice .Slice}}`,
+			"function eq: non-comparable type []github.com/motemen/go-template-statictools/templatetypes.Dot1ContainedValue",
+		},
+		{
+			"builtin eq with nil", `
+{{/* @type github.com/motemen/go-template-statictools/templatetypes.Dot1 */}}
+{{eq .Ptr nil}}
+{{ne nil .Map}}
+{{eq .Slice nil}}
+{{eq .Func1 nil}}
+{{eq .Intf nil}}
+{{eq .Any nil}}
+{{if ne .Ptr nil}}{{.Ptr.InnerField}}{{end}}`,
+			"",
+		},
+		{
+			"builtin ne with too many args", `
+{{/* @type github.com/motemen/go-template-statictools/templatetypes.Dot1 */}}
+{{ne .Foo "a" "b"}}`,
+			"function ne: wrong number of args: want 2 got 3",
+		},
+		{
+			"builtin lt with non-ordered type", `
+{{/* @type github.com/motemen/go-template-statictools/templatetypes.Dot1 */}}
+{{lt .Slice 5}}`,
+			"function lt: invalid type for comparison: []github.com/motemen/go-template-statictools/templatetypes.Dot1ContainedValue",
+		},
+		{
+			"builtin gt with bool", `
+{{/* @type github.com/motemen/go-template-statictools/templatetypes.Dot1 */}}
+{{gt true false}}`,
+			"function gt: invalid type for comparison: untyped bool",
+		},
+		{
+			"builtin le with incompatible types", `
+{{/* @type github.com/motemen/go-template-statictools/templatetypes.Dot1 */}}
+{{le .Foo 1}}`,
+			"function le: incompatible types for comparison: string and untyped int",
+		},
+		{
+			"builtin and/or", `
+{{/* @type github.com/motemen/go-template-statictools/templatetypes.Dot1 */}}
+{{(or .Inner .Inner).InnerField}}
+{{(and .Ptr .Ptr).InnerField}}
+{{len (or .Foo "default")}}
+{{len (or "default" .Foo)}}
+{{if and .Foo .Slice}}{{end}}`,
+			"",
+		},
+		{
+			"builtin or, invalid field", `
+{{/* @type github.com/motemen/go-template-statictools/templatetypes.Dot1 */}}
+{{(or .Inner .Inner).InvalidField}}`,
+			"can't evaluate field InvalidField in type github.com/motemen/go-template-statictools/templatetypes.Dot1Inner",
+		},
+		{
+			"builtin and without args", `
+{{/* @type github.com/motemen/go-template-statictools/templatetypes.Dot1 */}}
+{{and}}`,
+			"function and: wrong number of args: want at least 1 got 0",
+		},
+		{
+			"builtin or, wrong argument to len", `
+{{/* @type github.com/motemen/go-template-statictools/templatetypes.Dot1 */}}
+{{len (or .Inner.InnerField 0)}}`,
+			"function len: invalid argument type int",
+		},
+		{
+			"builtin printf", `
+{{/* @type github.com/motemen/go-template-statictools/templatetypes.Dot1 */}}
+{{printf "%s: %d" .Foo .Inner.InnerField}}
+{{printf "%v %+v %T" .Inner .Slice .Map}}
+{{printf "%5.2f%%" 1.5}}
+{{printf "%*d" 3 .Inner.InnerField}}
+{{printf "%[2]s %[1]d" 1 "x"}}
+{{printf "%x %q %c" .Foo .Foo 'a'}}
+{{printf "%d" .Inner}}
+{{printf "%s" .Intf}}
+{{.Foo | printf "%s"}}
+{{printf .Foo 1 2 3}}`,
+			"",
+		},
+		{
+			"builtin printf with wrong verb", `
+{{/* @type github.com/motemen/go-template-statictools/templatetypes.Dot1 */}}
+{{printf "%d" .Foo}}`,
+			"function printf: format %d has arg #1 of wrong type string",
+		},
+		{
+			"builtin printf with wrong verb for float", `
+{{/* @type github.com/motemen/go-template-statictools/templatetypes.Dot1 */}}
+{{printf "%d" 1.5}}`,
+			"function printf: format %d has arg #1 of wrong type untyped float",
+		},
+		{
+			"builtin printf with wrong verb for struct", `
+{{/* @type github.com/motemen/go-template-statictools/templatetypes.Dot1 */}}
+{{printf "%s" .Inner}}`,
+			"function printf: format %s has arg #1 of wrong type github.com/motemen/go-template-statictools/templatetypes.Dot1Inner",
+		},
+		{
+			"builtin printf with wrong verb in pipeline", `
+{{/* @type github.com/motemen/go-template-statictools/templatetypes.Dot1 */}}
+{{.Foo | printf "%s %t" "x"}}`,
+			"function printf: format %t has arg #2 of wrong type string",
+		},
+		{
+			"builtin printf with missing args", `
+{{/* @type github.com/motemen/go-template-statictools/templatetypes.Dot1 */}}
+{{printf "%s %s" .Foo}}`,
+			"function printf: format %s reads arg #2, but call has 1 args",
+		},
+		{
+			"builtin printf with extra args", `
+{{/* @type github.com/motemen/go-template-statictools/templatetypes.Dot1 */}}
+{{printf "%s" .Foo .Foo}}`,
+			"function printf: call needs 1 args but has 2 args",
+		},
+		{
+			"builtin printf with unknown verb", `
+{{/* @type github.com/motemen/go-template-statictools/templatetypes.Dot1 */}}
+{{printf "%z" .Foo}}`,
+			"function printf: format %z has unknown verb z",
+		},
+		{
+			"builtin printf with non-string format", `
+{{/* @type github.com/motemen/go-template-statictools/templatetypes.Dot1 */}}
+{{printf .Inner}}`,
+			"function printf: wrong type for argument 1: expected string; got github.com/motemen/go-template-statictools/templatetypes.Dot1Inner",
+		},
+		{
+			"builtin call with named function type", `
+{{/* @type github.com/motemen/go-template-statictools/templatetypes.Dot1 */}}
+{{(call .Func2 3).InnerField}}
+{{3 | call .Func2}}`,
+			"",
+		},
+		{
+			"builtin call with variadic function", `
+{{/* @type github.com/motemen/go-template-statictools/templatetypes.Dot1 */}}
+{{call .Func3}}
+{{call .Func3 "a" .Foo | len}}`,
+			"",
+		},
+		{
+			"builtin call with wrong number of args", `
+{{/* @type github.com/motemen/go-template-statictools/templatetypes.Dot1 */}}
+{{call .Func1 3}}`,
+			"function call: wrong number of args: want 2 got 1",
+		},
+		{
+			"builtin call with wrong arg type", `
+{{/* @type github.com/motemen/go-template-statictools/templatetypes.Dot1 */}}
+{{call .Func1 "foo" 3}}`,
+			"function call: wrong type for argument 1: expected int; got string",
+		},
+		{
+			"builtin call with wrong variadic arg type", `
+{{/* @type github.com/motemen/go-template-statictools/templatetypes.Dot1 */}}
+{{call .Func3 "a" .Inner}}`,
+			"function call: wrong type for argument 2: expected string; got github.com/motemen/go-template-statictools/templatetypes.Dot1Inner",
+		},
+		{
+			"builtin call with invalid result", `
+{{/* @type github.com/motemen/go-template-statictools/templatetypes.Dot1 */}}
+{{call .Func4}}`,
+			"function call: second return value must be error",
+		},
+		{
+			"builtin call on non-function", `
+{{/* @type github.com/motemen/go-template-statictools/templatetypes.Dot1 */}}
+{{call .Foo}}`,
+			"function call: expected function type, got string",
+		},
 		{
 			"builtin slice", `
 {{/* @type github.com/motemen/go-template-statictools/templatetypes.Dot1 */}}
 {{slice .Slice 1 2 | len}}`,
 			"",
 		},
-	}
-
-	for _, test := range tests {
-		t.Run(test.name, func(t *testing.T) {
-			var s Checker
-			err := s.Parse("", strings.NewReader(test.template))
-			assert.NilError(t, err)
-
-			err = s.Check("")
-			if test.errorMessage == "" {
-				assert.NilError(t, err)
-			} else {
-				assert.Error(t, err, test.errorMessage)
+		{
+			"builtin slice on string", `
+{{/* @type github.com/motemen/go-template-statictools/templatetypes.Dot1 */}}
+{{slice .Foo}}
+{{slice .Foo 1 | len}}
+{{slice .Foo 0 2 | printf "%s"}}`,
+			"",
+		},
+		{
+			"builtin slice with 3 indexes", `
+{{/* @type github.com/motemen/go-template-statictools/templatetypes.Dot1 */}}
+{{range slice .Slice 0 1 2}}{{.Value}}{{end}}`,
+			"",
+		},
+		{
+			"builtin slice on array yields slice", `
+{{/* @type github.com/motemen/go-template-statictools/templatetypes.Dot1 */}}
+{{$s := slice .Ptr.Array 1}}
+{{range $i, $v := $s}}{{$v}}{{end}}
+{{index $s 0}}`,
+			"",
+		},
+		{
+			"builtin slice on array yields slice, invalid", `
+{{/* @type github.com/motemen/go-template-statictools/templatetypes.Dot1 */}}
+{{$s := slice .Ptr.Array 1}}
+{{$s.Value}}`,
+			"can't evaluate field Value in type []int",
+		},
+		{
+			"builtin slice on struct", `
+{{/* @type github.com/motemen/go-template-statictools/templatetypes.Dot1 */}}
+{{slice .Inner 1}}`,
+			"function slice: can't slice item of type github.com/motemen/go-template-statictools/templatetypes.Dot1Inner",
+		},
+		{
+			"builtin slice with non-integer index", `
+{{/* @type github.com/motemen/go-template-statictools/templatetypes.Dot1 */}}
+{{slice .Slice "1"}}`,
+			"function slice: cannot index slice/array with type string",
+		},
+		{
+			"builtin slice on string with 3 indexes", `
+{{/* @type github.com/motemen/go-template-statictools/templatetypes.Dot1 */}}
+{{slice .Foo 0 1 2}}`,
+			"function slice: cannot 3-index slice a string",
+		},
+		{
+			"builtin slice with too many indexes", `
+{{/* @type github.com/motemen/go-template-statictools/templatetypes.Dot1 */}}
+{{slice .Slice 0 1 2 3}}`,
+			"function slice: too many slice indexes: 4",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := Checker{Tests: true}
+			err := s.Parse("", strings.NewReader(test.template))
+			assert.NilError(t, err)
+
+			err = s.Check("")
+			if test.errorMessage == "" {
+				assert.NilError(t, err)
+			} else {
+				assert.Error(t, err, test.errorMessage)
 			}
 		})
 	}
@@ -330,6 +917,20 @@ func TestCheck(t *testing.T) {
 					}
 				},
 				Intf: Dot1InnerImpl("inner"),
+				Ptr:  &Dot1Inner{InnerField: 1},
+				Func2: func(n int) Dot1Inner {
+					return Dot1Inner{InnerField: n}
+				},
+				Func3: func(ss ...string) (string, error) {
+					return strings.Join(ss, ""), nil
+				},
+				Func4: func() (string, int) {
+					return "", 0
+				},
+				FuncI: func(n int64) int64 { return n },
+				FuncF: func(f float64) float64 { return f },
+				FuncS: func(s Dot1Inner) int { return s.InnerField },
+				Any:   Dot1Inner{InnerField: 2},
 			}
 			var buf bytes.Buffer
 			err := tmpl.Execute(&buf, dot1)
@@ -343,3 +944,1201 @@ func TestCheck(t *testing.T) {
 		})
 	}
 }
+
+var testFuncs = template.FuncMap{
+	"upper":  strings.ToUpper,
+	"repeat": strings.Repeat,
+	"join":   func(sep string, elems ...string) string { return strings.Join(elems, sep) },
+	"inner":  func(n int) Dot1Inner { return Dot1Inner{InnerField: n} },
+}
+
+func TestCheckFuncMap(t *testing.T) {
+	type testCase struct {
+		name         string
+		template     string
+		errorMessage string
+	}
+
+	tests := []testCase{
+		{
+			"function", `
+{{/* @type github.com/motemen/go-template-statictools/templatetypes.Dot1 */}}
+{{upper .Foo}}
+{{repeat .Foo 3}}
+{{(inner 1).InnerField}}`,
+			"",
+		},
+		{
+			"pipeline", `
+{{/* @type github.com/motemen/go-template-statictools/templatetypes.Dot1 */}}
+{{.Foo | upper}}
+{{3 | repeat .Foo | upper}}
+{{3 | inner}}`,
+			"",
+		},
+		{
+			"variadic", `
+{{/* @type github.com/motemen/go-template-statictools/templatetypes.Dot1 */}}
+{{join ","}}
+{{join "," .Foo}}
+{{join "," "a" "b" .Foo}}`,
+			"",
+		},
+		{
+			"too few args", `
+{{/* @type github.com/motemen/go-template-statictools/templatetypes.Dot1 */}}
+{{repeat .Foo}}`,
+			"function repeat: wrong number of args: want 2 got 1",
+		},
+		{
+			"too many args", `
+{{/* @type github.com/motemen/go-template-statictools/templatetypes.Dot1 */}}
+{{upper .Foo "x"}}`,
+			"function upper: wrong number of args: want 1 got 2",
+		},
+		{
+			"too many args with pipeline", `
+{{/* @type github.com/motemen/go-template-statictools/templatetypes.Dot1 */}}
+{{.Foo | upper "x"}}`,
+			"function upper: wrong number of args: want 1 got 2",
+		},
+		{
+			"too few args for variadic", `
+{{/* @type github.com/motemen/go-template-statictools/templatetypes.Dot1 */}}
+{{join}}`,
+			"function join: wrong number of args: want at least 1 got 0",
+		},
+		{
+			"wrong arg type", `
+{{/* @type github.com/motemen/go-template-statictools/templatetypes.Dot1 */}}
+{{repeat 1 "x"}}`,
+			"function repeat: wrong type for argument 1: expected string; got untyped int",
+		},
+		{
+			"wrong arg type from field", `
+{{/* @type github.com/motemen/go-template-statictools/templatetypes.Dot1 */}}
+{{upper .Inner}}`,
+			"function upper: wrong type for argument 1: expected string; got github.com/motemen/go-template-statictools/templatetypes.Dot1Inner",
+		},
+		{
+			"wrong variadic arg type", `
+{{/* @type github.com/motemen/go-template-statictools/templatetypes.Dot1 */}}
+{{join "," "a" .Inner.InnerField}}`,
+			"function join: wrong type for argument 3: expected string; got int",
+		},
+		{
+			"wrong arg type with pipeline", `
+{{/* @type github.com/motemen/go-template-statictools/templatetypes.Dot1 */}}
+{{.Foo | inner}}`,
+			"function inner: wrong type for argument 1: expected int; got string",
+		},
+		{
+			"function as an argument", `
+{{/* @type github.com/motemen/go-template-statictools/templatetypes.Dot1 */}}
+{{repeat .Foo join}}`,
+			"function join: wrong number of args: want at least 1 got 0",
+		},
+		{
+			"wrong arg type in the middle of pipeline", `
+{{/* @type github.com/motemen/go-template-statictools/templatetypes.Dot1 */}}
+{{.Foo | repeat "x" | upper}}`,
+			"function repeat: wrong type for argument 2: expected int; got string",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := Checker{
+				Tests:      true,
+				FuncMapVar: "github.com/motemen/go-template-statictools/templatetypes.testFuncs",
+			}
+			err := s.Parse("", strings.NewReader(test.template))
+			assert.NilError(t, err)
+
+			err = s.Check("")
+			if test.errorMessage == "" {
+				assert.NilError(t, err)
+			} else {
+				assert.Error(t, err, test.errorMessage)
+			}
+		})
+	}
+
+	for _, test := range tests {
+		t.Run("sanity check - "+test.name, func(t *testing.T) {
+			tmpl, err := template.New(test.name).Funcs(testFuncs).Parse(test.template)
+			if err != nil {
+				if test.errorMessage == "" {
+					t.Fatal(err)
+				}
+				return
+			}
+			var buf bytes.Buffer
+			err = tmpl.Execute(&buf, Dot1{Foo: "foo"})
+			if test.errorMessage == "" {
+				assert.NilError(t, err)
+				t.Logf("%q -> %q", test.template, buf.String())
+			}
+		})
+	}
+}
+
+type RangeDot struct {
+	Count    int
+	Small    uint8
+	Seq      func(yield func(Dot1ContainedValue) bool)
+	Seq2     func(yield func(string, Dot1ContainedValue) bool)
+	Chan     chan Dot1ContainedValue
+	SendChan chan<- Dot1ContainedValue
+	Func     func(n int) bool
+}
+
+func TestCheckRange(t *testing.T) {
+	type testCase struct {
+		name         string
+		template     string
+		errorMessage string
+	}
+
+	tests := []testCase{
+		{
+			"range over integer", `
+{{/* @type github.com/motemen/go-template-statictools/templatetypes.RangeDot */}}
+{{range 3}}{{printf "%d" .}}{{end}}
+{{range $i := .Count}}{{printf "%d" $i}}{{end}}
+{{range .Small}}{{len (slice "abcdefg" 0 .)}}{{end}}`,
+			"",
+		},
+		{
+			"range over integer, invalid", `
+{{/* @type github.com/motemen/go-template-statictools/templatetypes.RangeDot */}}
+{{range .Count}}{{.Value}}{{end}}`,
+			"can't evaluate field Value in type int",
+		},
+		{
+			"range over unknown type", `
+{{/* @type github.com/motemen/go-template-statictools/templatetypes.RangeDot */}}
+{{range $v := .Unknown}}{{$v.Anything}}{{$.Nope}}{{else}}{{$.Nope}}{{end}}
+{{with .Unknown}}{{range .}}{{$.Count.Nope}}{{end}}{{end}}`,
+			"can't evaluate field Unknown in type github.com/motemen/go-template-statictools/templatetypes.RangeDot\n" +
+				"can't evaluate field Nope in type github.com/motemen/go-template-statictools/templatetypes.RangeDot\n" +
+				"can't evaluate field Nope in type github.com/motemen/go-template-statictools/templatetypes.RangeDot\n" +
+				"can't evaluate field Unknown in type github.com/motemen/go-template-statictools/templatetypes.RangeDot\n" +
+				"can't evaluate field Nope in type int",
+		},
+		{
+			"range over integer with two variables", `
+{{/* @type github.com/motemen/go-template-statictools/templatetypes.RangeDot */}}
+{{range $i, $v := .Count}}{{end}}`,
+			"can't use int to iterate over more than one variable",
+		},
+		{
+			"range over iter.Seq", `
+{{/* @type github.com/motemen/go-template-statictools/templatetypes.RangeDot */}}
+{{range .Seq}}{{.Value}}{{end}}
+{{range $v := .Seq}}{{$v.Value}}{{end}}`,
+			"",
+		},
+		{
+			"range over iter.Seq, invalid", `
+{{/* @type github.com/motemen/go-template-statictools/templatetypes.RangeDot */}}
+{{range .Seq}}{{.Invalid}}{{end}}`,
+			"can't evaluate field Invalid in type github.com/motemen/go-template-statictools/templatetypes.Dot1ContainedValue",
+		},
+		{
+			"range over iter.Seq with two variables", `
+{{/* @type github.com/motemen/go-template-statictools/templatetypes.RangeDot */}}
+{{range $k, $v := .Seq}}{{end}}`,
+			"can't use func(yield func(github.com/motemen/go-template-statictools/templatetypes.Dot1ContainedValue) bool) to iterate over more than one variable",
+		},
+		{
+			"range over iter.Seq2", `
+{{/* @type github.com/motemen/go-template-statictools/templatetypes.RangeDot */}}
+{{range $k, $v := .Seq2}}{{len $k}}{{$v.Value}}{{.Value}}{{end}}
+{{range $k := .Seq2}}{{len $k}}{{len .}}{{end}}`,
+			"",
+		},
+		{
+			"range over iter.Seq2 with one variable, invalid", `
+{{/* @type github.com/motemen/go-template-statictools/templatetypes.RangeDot */}}
+{{range $k := .Seq2}}{{$k.Value}}{{end}}`,
+			"can't evaluate field Value in type string",
+		},
+		{
+			"range over non-iterator function", `
+{{/* @type github.com/motemen/go-template-statictools/templatetypes.RangeDot */}}
+{{range .Func}}{{end}}`,
+			"range can't iterate over func(n int) bool, pipe: .Func",
+		},
+		{
+			"range over channel", `
+{{/* @type github.com/motemen/go-template-statictools/templatetypes.RangeDot */}}
+{{range $v := .Chan}}{{$v.Value}}{{.Value}}{{end}}`,
+			"",
+		},
+		{
+			"range over channel with two variables", `
+{{/* @type github.com/motemen/go-template-statictools/templatetypes.RangeDot */}}
+{{range $i, $v := .Chan}}{{end}}`,
+			"can't use chan github.com/motemen/go-template-statictools/templatetypes.Dot1ContainedValue to iterate over more than one variable",
+		},
+		{
+			"range over send-only channel", `
+{{/* @type github.com/motemen/go-template-statictools/templatetypes.RangeDot */}}
+{{range .SendChan}}{{end}}`,
+			"range over send-only channel chan<- github.com/motemen/go-template-statictools/templatetypes.Dot1ContainedValue",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := Checker{Tests: true}
+			err := s.Parse("", strings.NewReader(test.template))
+			assert.NilError(t, err)
+
+			err = s.Check("")
+			if test.errorMessage == "" {
+				assert.NilError(t, err)
+			} else {
+				assert.Error(t, err, test.errorMessage)
+			}
+		})
+	}
+
+	for _, test := range tests {
+		t.Run("sanity check - "+test.name, func(t *testing.T) {
+			// range over integers and functions requires newer text/template
+			if err := template.Must(template.New("").Parse(`{{range 1}}{{end}}`)).Execute(io.Discard, nil); err != nil {
+				t.Skip(err)
+			}
+
+			tmpl := template.Must(template.New(test.name).Parse(test.template))
+			values := []Dot1ContainedValue{{Value: true}, {Value: false}}
+			ch := make(chan Dot1ContainedValue, len(values))
+			for _, v := range values {
+				ch <- v
+			}
+			close(ch)
+			dot := RangeDot{
+				Count: 3,
+				Small: 2,
+				Seq: func(yield func(Dot1ContainedValue) bool) {
+					for _, v := range values {
+						if !yield(v) {
+							return
+						}
+					}
+				},
+				Seq2: func(yield func(string, Dot1ContainedValue) bool) {
+					for _, v := range values {
+						if !yield(fmt.Sprint(v.Value), v) {
+							return
+						}
+					}
+				},
+				Chan: ch,
+			}
+			var buf bytes.Buffer
+			err := tmpl.Execute(&buf, dot)
+			if test.errorMessage == "" {
+				assert.NilError(t, err)
+				t.Logf("%q -> %q", test.template, buf.String())
+			}
+		})
+	}
+}
+
+type HTMLDot struct {
+	Title  string
+	Body   htmltemplate.HTML
+	Link   htmltemplate.URL
+	Script htmltemplate.JS
+	Attr   htmltemplate.HTMLAttr
+	Style  htmltemplate.CSS
+}
+
+func (HTMLDot) SafeLink(s string) htmltemplate.URL {
+	return htmltemplate.URL(s)
+}
+
+var testHTMLFuncs = template.FuncMap{
+	"safeHTML": func(s string) htmltemplate.HTML { return htmltemplate.HTML(s) },
+}
+
+func TestCheckHTML(t *testing.T) {
+	type testCase struct {
+		name         string
+		template     string
+		errorMessage string
+	}
+
+	tests := []testCase{
+		{
+			"trusted types in their contexts", `
+{{/* @type github.com/motemen/go-template-statictools/templatetypes.HTMLDot */}}
+<title>{{.Title}}</title>
+<div title="{{.Title}}" {{.Attr}}>{{.Body}}</div>
+<a href="{{.Link}}" onclick="f({{.Script}})">{{.Title}}</a>
+<img src={{.Link}} alt='{{.Title}}'>
+<!-- {{.Body}} -->
+<script>var x = {{.Script}};</script>
+<style>p { {{.Style}} }</style>
+<p style="{{.Style}}">{{safeHTML "<b>constant</b>"}}</p>`,
+			"",
+		},
+		{
+			"HTML in JS", `
+{{/* @type github.com/motemen/go-template-statictools/templatetypes.HTMLDot */}}
+<script>var x = {{.Body}};</script>`,
+			"value of type html/template.HTML is used in JS context, where it is not trusted",
+		},
+		{
+			"HTML in event handler attribute", `
+{{/* @type github.com/motemen/go-template-statictools/templatetypes.HTMLDot */}}
+<button onclick='alert({{.Body}})'>`,
+			"value of type html/template.HTML is used in JS context, where it is not trusted",
+		},
+		{
+			"HTML in attribute", `
+{{/* @type github.com/motemen/go-template-statictools/templatetypes.HTMLDot */}}
+<div title={{.Body}}>`,
+			"value of type html/template.HTML is used in attribute context, where it is not trusted",
+		},
+		{
+			"URL in CSS", `
+{{/* @type github.com/motemen/go-template-statictools/templatetypes.HTMLDot */}}
+<style>
+{{if .Title}}p { background: {{.Link}} }{{end}}
+</style>`,
+			"value of type html/template.URL is used in CSS context, where it is not trusted",
+		},
+		{
+			"URL in text", `
+{{/* @type github.com/motemen/go-template-statictools/templatetypes.HTMLDot */}}
+<a href="{{.Link}}">{{.Link}}</a>`,
+			"value of type html/template.URL is used in HTML context, where it is not trusted",
+		},
+		{
+			"context after branches", `
+{{/* @type github.com/motemen/go-template-statictools/templatetypes.HTMLDot */}}
+{{if .Title}}<a href="{{.Link}}">{{else}}<a>{{end}}{{.Body}}</a>`,
+			"",
+		},
+		{
+			"context carried into template", `
+{{/* @type github.com/motemen/go-template-statictools/templatetypes.HTMLDot */}}
+{{define "script"}}var x = {{.Body}};{{end}}
+<div>{{template "script" .}}</div>
+<script>{{template "script" .}}</script>`,
+			"value of type html/template.HTML is used in JS context, where it is not trusted",
+		},
+		{
+			"context after template", `
+{{/* @type github.com/motemen/go-template-statictools/templatetypes.HTMLDot */}}
+{{define "open"}}<script>{{end}}
+{{template "open" .}}var x = {{.Body}};</script>`,
+			"value of type html/template.HTML is used in JS context, where it is not trusted",
+		},
+		{
+			"branches closing tags after bare attributes", `
+{{/* @type github.com/motemen/go-template-statictools/templatetypes.HTMLDot */}}
+{{if .Title}}<input onclick>{{else}}<input>{{end}}{{.Body}}`,
+			"",
+		},
+		{
+			"branches ending in different contexts", `
+{{/* @type github.com/motemen/go-template-statictools/templatetypes.HTMLDot */}}
+{{if .Title}}<a href="{{else}}<a title="{{end}}{{.Link}}">`,
+			"{{if}} branches end in different contexts: URL, attribute",
+		},
+		{
+			"range ending in different context", `
+{{/* @type github.com/motemen/go-template-statictools/templatetypes.HTMLDot */}}
+{{range 3}}<script>{{end}}</script>`,
+			"{{range}} branches end in different contexts: JS, HTML",
+		},
+		{
+			"conversion from string field", `
+{{/* @type github.com/motemen/go-template-statictools/templatetypes.HTMLDot */}}
+<a href="{{.SafeLink .Title}}">`,
+			"function SafeLink converts string to html/template.URL, bypassing escaping",
+		},
+		{
+			"conversion from string in pipeline", `
+{{/* @type github.com/motemen/go-template-statictools/templatetypes.HTMLDot */}}
+<div>{{.Title | safeHTML}}</div>`,
+			"function safeHTML converts string to html/template.HTML, bypassing escaping",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := Checker{
+				Tests:      true,
+				FuncMapVar: "github.com/motemen/go-template-statictools/templatetypes.testHTMLFuncs",
+				HTML:       true,
+			}
+			err := s.Parse("", strings.NewReader(test.template))
+			assert.NilError(t, err)
+
+			err = s.Check("")
+			if test.errorMessage == "" {
+				assert.NilError(t, err)
+			} else {
+				assert.Error(t, err, test.errorMessage)
+			}
+		})
+	}
+
+	for _, test := range tests {
+		t.Run("sanity check - "+test.name, func(t *testing.T) {
+			tmpl := htmltemplate.Must(htmltemplate.New(test.name).Funcs(htmltemplate.FuncMap(testHTMLFuncs)).Parse(test.template))
+			var buf bytes.Buffer
+			err := tmpl.Execute(&buf, HTMLDot{
+				Title:  "title",
+				Body:   "<b>body</b>",
+				Link:   "https://example.com/",
+				Script: "1 + 1",
+				Attr:   `class="x"`,
+				Style:  "color: red",
+			})
+			if test.errorMessage == "" {
+				assert.NilError(t, err)
+				t.Logf("%q -> %q", test.template, buf.String())
+			}
+		})
+	}
+}
+
+func TestLoadFuncMap(t *testing.T) {
+	const pkg = "github.com/motemen/go-template-statictools/templatetypes/testdata/funcmaps"
+
+	type testCase struct {
+		name         string
+		funcMap      map[string]string
+		errorMessage string
+	}
+
+	tests := []testCase{
+		{
+			"Literal",
+			map[string]string{
+				"upper":  "func(s string) string",
+				"lower":  "func(s string) string",
+				"repeat": "func(s string, count int) string",
+			},
+			"",
+		},
+		{
+			"FromFunc",
+			map[string]string{
+				"upper":  "func(s string) string",
+				"repeat": "func(s string, count int) string",
+			},
+			"",
+		},
+		{
+			"FromInit",
+			map[string]string{
+				"upper": "func(s string) string",
+				"trim":  "func(s string) string",
+			},
+			"",
+		},
+		{
+			"InitOrder",
+			map[string]string{
+				"upper": "func(s string) string",
+			},
+			"",
+		},
+		{
+			"FromOtherPackage",
+			map[string]string{
+				"upper":  "func(s string) string",
+				"repeat": "func(s string, count int) string",
+			},
+			"",
+		},
+		{
+			"FromVar",
+			map[string]string{
+				"upper":  "func(s string) string",
+				"lower":  "func(s string) string",
+				"repeat": "func(s string, count int) string",
+			},
+			"",
+		},
+		{
+			"HTMLFuncMap",
+			map[string]string{
+				"upper": "func(s string) string",
+			},
+			"",
+		},
+		{
+			"PlainMap",
+			map[string]string{
+				"upper": "func(s string) string",
+			},
+			"",
+		},
+		{
+			"PlainMapInterface",
+			map[string]string{
+				"upper": "func(s string) string",
+			},
+			"",
+		},
+		{
+			"NotFuncMap",
+			nil,
+			"NotFuncMap is not a FuncMap",
+		},
+		{
+			"NonConstantKey",
+			nil,
+			"cannot resolve FuncMap key: must be a constant string",
+		},
+		{
+			"NotAFunction",
+			nil,
+			`cannot resolve FuncMap entry "upper": not a function`,
+		},
+		{
+			"FromParam",
+			nil,
+			"cannot resolve FuncMap from parameter m",
+		},
+		{
+			"NoSuchVar",
+			nil,
+			"cannot find " + pkg + ".NoSuchVar",
+		},
+	}
+
+	for _, tag := range build.Default.ReleaseTags {
+		if tag != "go1.21" {
+			continue
+		}
+		// maps.Copy is available
+		tests = append(tests, testCase{
+			"Merged",
+			map[string]string{
+				"upper":  "func(s string) string",
+				"repeat": "func(s string, count int) string",
+			},
+			"",
+		})
+	}
+
+	s := Checker{Tests: true}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			funcMap, err := s.loadFuncMap(pkg + "." + test.name)
+			if test.errorMessage != "" {
+				assert.ErrorContains(t, err, test.errorMessage)
+				return
+			}
+			assert.NilError(t, err)
+
+			got := map[string]string{}
+			for name, sig := range funcMap {
+				got[name] = types.TypeString(sig, nil)
+			}
+			assert.DeepEqual(t, got, test.funcMap)
+		})
+	}
+}
+
+func TestLoadFuncMapEntryUnknownType(t *testing.T) {
+	// the type of an entry is missing from packages failed to type-check
+	key := &ast.BasicLit{Kind: token.STRING, Value: `"upper"`}
+	pkg := &packages.Package{
+		Fset: token.NewFileSet(),
+		TypesInfo: &types.Info{
+			Types: map[ast.Expr]types.TypeAndValue{
+				key: {Type: types.Typ[types.UntypedString], Value: constant.MakeString("upper")},
+			},
+		},
+	}
+
+	l := funcMapLoader{funcMap: map[string]*types.Signature{}}
+	err := l.loadEntry(pkg, key, ast.NewIdent("undefined"))
+	assert.ErrorContains(t, err, `cannot resolve FuncMap entry "upper": unknown type`)
+}
+
+func TestCheckMultipleFuncMaps(t *testing.T) {
+	const pkg = "github.com/motemen/go-template-statictools/templatetypes/testdata/funcmaps"
+
+	tests := []struct {
+		funcMapVar   string
+		errorMessage string
+	}{
+		{pkg + ".Literal," + pkg + ".Override", ""},
+		{pkg + ".Override, " + pkg + ".Literal", "function upper: wrong type for argument 1: expected string; got untyped int"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.funcMapVar, func(t *testing.T) {
+			s := Checker{
+				Tests:      true,
+				FuncMapVar: test.funcMapVar,
+			}
+			err := s.Parse("", strings.NewReader(`{{upper 1 | printf "%d"}}{{repeat "x" 2}}`))
+			assert.NilError(t, err)
+
+			err = s.Check("")
+			if test.errorMessage == "" {
+				assert.NilError(t, err)
+			} else {
+				assert.Error(t, err, test.errorMessage)
+			}
+		})
+	}
+}
+
+func TestCheckCatalog(t *testing.T) {
+	RegisterCatalog("test", map[string]FuncChecker{
+		"double": fn(types.Typ[types.Int], types.Typ[types.Int]),
+	})
+
+	tests := []struct {
+		catalogs     []string
+		template     string
+		errorMessage string
+	}{
+		{[]string{"sprig"}, `{{upper "x" | printf "%s"}}`, ""},
+		{[]string{"sprig"}, `{{upper 1}}`, "function upper: wrong type for argument 1: expected string; got untyped int"},
+		{[]string{"sprig"}, `{{"x" | trimPrefix "y" | len}}`, ""},
+		{[]string{"sprig"}, `{{repeat "x" 2}}`, "function repeat: wrong type for argument 1: expected int; got string"},
+		{[]string{"sprig"}, `{{quote 1 "a" true}}`, ""},
+		{[]string{"sprig"}, `{{(dict "a" 1 "b" 2).a}}`, ""},
+		{[]string{"sprig"}, `{{dict "a" 1 "b"}}`, ""},
+		{[]string{"sprig"}, `{{dict 1 2}}`, ""},
+		{[]string{"sprig"}, `{{(get (dict "a" now) "a").Year}}`, ""},
+		{[]string{"sprig"}, `{{(first (splitList "," "a,b")).Foo}}`, "can't evaluate field Foo in type string"},
+		{[]string{"sprig"}, `{{default "x" "y" | printf "%d"}}`, `function printf: format %d has arg #1 of wrong type string`},
+		{[]string{"sprig"}, `{{ternary 1 2 "yes"}}`, "function ternary: wrong type for argument 3: expected bool; got string"},
+		{[]string{"sprig"}, `{{now.Year}}`, ""},
+		{[]string{"sprig"}, `{{double 1}}`, `function "double" not found`},
+		{[]string{"test"}, `{{double 1 | printf "%d"}}`, ""},
+		{[]string{"sprig", "test"}, `{{double "x"}}`, "function double: wrong type for argument 1: expected int; got string"},
+		{[]string{"nonexistent"}, `{{.}}`, `unknown catalog "nonexistent"`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.template, func(t *testing.T) {
+			s := Checker{
+				Tests:    true,
+				Catalogs: test.catalogs,
+			}
+			err := s.Parse("", strings.NewReader(test.template))
+			assert.NilError(t, err)
+
+			err = s.Check("")
+			if test.errorMessage == "" {
+				assert.NilError(t, err)
+			} else {
+				assert.Error(t, err, test.errorMessage)
+			}
+		})
+	}
+}
+
+func TestCheckCatalogFuncMapPrecedence(t *testing.T) {
+	s := Checker{
+		Tests:      true,
+		FuncMapVar: "github.com/motemen/go-template-statictools/templatetypes/testdata/funcmaps.Override",
+		Catalogs:   []string{"sprig"},
+	}
+	err := s.Parse("", strings.NewReader(`{{upper 1 | printf "%d"}}`))
+	assert.NilError(t, err)
+	assert.NilError(t, s.Check(""))
+}
+
+func TestCheckFuncs(t *testing.T) {
+	// head returns the first element of a slice
+	checkHead := func(dot types.Type, args []types.Type) (types.Type, error) {
+		if len(args) != 1 {
+			return nil, fmt.Errorf("wrong number of args: want 1 got %d", len(args))
+		}
+		slice, ok := args[0].Underlying().(*types.Slice)
+		if !ok {
+			return nil, fmt.Errorf("not a slice: %s", args[0])
+		}
+		return slice.Elem(), nil
+	}
+
+	tests := []struct {
+		name         string
+		template     string
+		errorMessage string
+	}{
+		{
+			"result type", `
+{{/* @type github.com/motemen/go-template-statictools/templatetypes.Dot1 */}}
+{{(head .Slice).Value}}
+{{.Slice | head | printf "%v"}}`,
+			"",
+		},
+		{
+			"wrong field of result", `
+{{/* @type github.com/motemen/go-template-statictools/templatetypes.Dot1 */}}
+{{(head .Slice).Foo}}`,
+			"can't evaluate field Foo in type github.com/motemen/go-template-statictools/templatetypes.Dot1ContainedValue",
+		},
+		{
+			"error", `
+{{/* @type github.com/motemen/go-template-statictools/templatetypes.Dot1 */}}
+{{head .Foo}}`,
+			"function head: not a slice: string",
+		},
+		{
+			"precedence over FuncMap", `
+{{/* @type github.com/motemen/go-template-statictools/templatetypes.Dot1 */}}
+{{(upper .Slice).Value}}`,
+			"",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := Checker{
+				Tests:      true,
+				FuncMapVar: "github.com/motemen/go-template-statictools/templatetypes.testFuncs",
+				Funcs: map[string]FuncChecker{
+					"head":  checkHead,
+					"upper": checkHead,
+				},
+			}
+			err := s.Parse("", strings.NewReader(test.template))
+			assert.NilError(t, err)
+
+			err = s.Check("")
+			if test.errorMessage == "" {
+				assert.NilError(t, err)
+			} else {
+				assert.Error(t, err, test.errorMessage)
+			}
+
+			if strings.HasPrefix(test.errorMessage, "function ") {
+				var tcErr TypeCheckError
+				assert.Assert(t, errors.As(err, &tcErr))
+				_, ok := tcErr.Node.(*parse.CommandNode)
+				assert.Assert(t, ok, "error node: %T", tcErr.Node)
+			}
+		})
+	}
+}
+
+func TestNumberType(t *testing.T) {
+	tests := []struct {
+		text string
+		typ  types.BasicKind
+	}{
+		{"1", types.UntypedInt},
+		{"-1", types.UntypedInt},
+		{"0x1F", types.UntypedInt},
+		{"1e3", types.UntypedFloat},
+		{"1.5", types.UntypedFloat},
+		{"0x1p-2", types.UntypedFloat},
+		{"'a'", types.UntypedRune},
+		{"1i", types.UntypedComplex},
+	}
+
+	for _, test := range tests {
+		t.Run(test.text, func(t *testing.T) {
+			trees, err := parse.Parse("", "{{"+test.text+"}}", "", "")
+			assert.NilError(t, err)
+
+			action := trees[""].Root.Nodes[0].(*parse.ActionNode)
+			number := action.Pipe.Cmds[0].Args[0].(*parse.NumberNode)
+			assert.Equal(t, numberType(number), types.Typ[test.typ])
+		})
+	}
+}
+
+func TestCheckTemplateCallers(t *testing.T) {
+	s := Checker{Tests: true}
+	err := s.Parse("page", strings.NewReader(`{{define "row"}}{{.InnerField}}{{end}}
+{{define "list"}}{{template "row" .}}{{end}}
+{{/* @type github.com/motemen/go-template-statictools/templatetypes.Dot1 */}}
+{{template "row" .Inner}}
+{{template "list" .}}
+{{template "row" .}}`))
+	assert.NilError(t, err)
+
+	err = s.Check("page")
+	var messages []string
+	for _, err := range err.(interface{ Unwrap() []error }).Unwrap() {
+		messages = append(messages, s.FormatError(err))
+	}
+	assert.DeepEqual(t, messages, []string{
+		"page:1:18: in .InnerField: can't evaluate field InnerField in type github.com/motemen/go-template-statictools/templatetypes.Dot1" +
+			" (template \"row\" is valid for data of type github.com/motemen/go-template-statictools/templatetypes.Dot1Inner passed by other callers)\n" +
+			"\tpage:2:28: called from {{template \"row\" .}}\n" +
+			"\tpage:5:11: called from {{template \"list\" .}}",
+		"page:1:18: in .InnerField: can't evaluate field InnerField in type github.com/motemen/go-template-statictools/templatetypes.Dot1" +
+			" (template \"row\" is valid for data of type github.com/motemen/go-template-statictools/templatetypes.Dot1Inner passed by other callers)\n" +
+			"\tpage:6:11: called from {{template \"row\" .}}",
+	})
+}
+
+func TestSignatures(t *testing.T) {
+	s := Checker{Tests: true}
+	err := s.Parse("page", strings.NewReader(`{{define "field"}}{{.InnerField}}{{end}}
+{{define "any"}}{{.}}{{end}}
+{{define "method"}}{{.InnerMethod}}{{end}}
+{{define "declared"}}{{/* @param github.com/motemen/go-template-statictools/templatetypes.Dot1Inner */}}{{end}}
+{{define "unused"}}{{template "field" .}}{{template "orphan"}}{{end}}
+{{/* @type github.com/motemen/go-template-statictools/templatetypes.Dot1 */}}
+{{template "field" .Inner}}{{template "field" .Inner}}
+{{template "any" .Foo}}{{template "any" .Inner}}
+{{template "method" .Intf}}{{template "method" .Intf}}
+{{template "declared" .Any}}`))
+	assert.NilError(t, err)
+	assert.NilError(t, s.Check("page"))
+
+	var got []string
+	for _, sig := range s.Signatures() {
+		got = append(got, fmt.Sprintf("%s declared=%v calls=%d", sig, sig.Declared, len(sig.Calls)))
+	}
+	assert.DeepEqual(t, got, []string{
+		`template "any" (unknown) declared=false calls=2`,
+		`template "declared" (github.com/motemen/go-template-statictools/templatetypes.Dot1Inner) declared=true calls=1`,
+		`template "field" (github.com/motemen/go-template-statictools/templatetypes.Dot1Inner) declared=false calls=3`,
+		`template "method" (github.com/motemen/go-template-statictools/templatetypes.Dot1InnerInterface) declared=false calls=2`,
+		`template "orphan" (unknown) declared=false calls=1`,
+	})
+}
+
+func TestCheckBlockOverride(t *testing.T) {
+	tests := []struct {
+		name      string
+		base      string
+		overrides []string
+		errors    []string
+	}{
+		{
+			"valid overrides",
+			`{{/* @type github.com/motemen/go-template-statictools/templatetypes.Dot1 */}}{{block "content" .Inner}}{{.InnerField}}{{end}}`,
+			[]string{
+				`{{define "content"}}{{.Array}}{{end}}`,
+				// empty definitions do not override
+				`{{define "content"}} {{end}}`,
+			},
+			nil,
+		},
+		{
+			"override expecting different type",
+			`{{/* @type github.com/motemen/go-template-statictools/templatetypes.Dot1 */}}{{block "content" .Inner}}{{.InnerField}}{{end}}`,
+			[]string{
+				`{{define "content"}}{{.Array}}{{end}}`,
+				`{{define "content"}}{{.Foo}}{{end}}`,
+			},
+			[]string{
+				"override1:1:22: in .Foo: can't evaluate field Foo in type github.com/motemen/go-template-statictools/templatetypes.Dot1Inner" +
+					" (template \"content\" overrides the base definition expecting github.com/motemen/go-template-statictools/templatetypes.Dot1Inner)\n" +
+					"\tbase:1:85: called from {{template \"content\" .Inner}}",
+			},
+		},
+		{
+			"redefinition without block",
+			`{{/* @type github.com/motemen/go-template-statictools/templatetypes.Dot1 */}}{{define "content"}}{{.Foo}}{{end}}{{template "content" .Inner}}`,
+			[]string{
+				// only the last definition is executed
+				`{{define "content"}}{{.InnerField}}{{end}}`,
+			},
+			nil,
+		},
+		{
+			"override declaring different type",
+			`{{/* @type github.com/motemen/go-template-statictools/templatetypes.Dot1 */}}{{block "content" .Inner}}{{/* @param github.com/motemen/go-template-statictools/templatetypes.Dot1Inner */}}{{.InnerField}}{{end}}`,
+			[]string{
+				`{{define "content"}}{{/* @param github.com/motemen/go-template-statictools/templatetypes.Dot1 */}}{{.Foo}}{{end}}`,
+			},
+			[]string{
+				`base:1:85: in {{template "content" .Inner}}: template "content": wrong type for data: expected github.com/motemen/go-template-statictools/templatetypes.Dot1; got github.com/motemen/go-template-statictools/templatetypes.Dot1Inner`,
+				`override0:1:22: in {{/* @param github.com/motemen/go-template-statictools/templatetypes.Dot1 */}}: template "content" expects github.com/motemen/go-template-statictools/templatetypes.Dot1, while the base definition expects github.com/motemen/go-template-statictools/templatetypes.Dot1Inner`,
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := Checker{Tests: true}
+			assert.NilError(t, s.Parse("base", strings.NewReader(test.base)))
+			for i, override := range test.overrides {
+				assert.NilError(t, s.Parse(fmt.Sprintf("override%d", i), strings.NewReader(override)))
+			}
+
+			err := s.Check("base")
+			var messages []string
+			if err != nil {
+				for _, err := range err.(interface{ Unwrap() []error }).Unwrap() {
+					messages = append(messages, s.FormatError(err))
+				}
+			}
+			assert.DeepEqual(t, messages, test.errors)
+		})
+	}
+}
+
+func TestCheckInfo(t *testing.T) {
+	s := Checker{Info: &Info{}, Tests: true}
+	err := s.Parse("", strings.NewReader(`
+{{/* @type github.com/motemen/go-template-statictools/templatetypes.Dot1 */}}
+{{range .Slice}}{{.Value}}{{end}}
+{{$m := .Map}}{{$m.k.Value}}`))
+	assert.NilError(t, err)
+	assert.NilError(t, s.Check(""))
+
+	types := map[string]string{}
+	for node, typ := range s.Info.Types {
+		types[node.String()] = typ.String()
+	}
+	assert.Equal(t, types[".Slice"], "[]github.com/motemen/go-template-statictools/templatetypes.Dot1ContainedValue")
+	assert.Equal(t, types[".Value"], "bool")
+	assert.Equal(t, types["$m.k.Value"], "bool")
+
+	dots := map[string]string{}
+	for node, typ := range s.Info.Dots {
+		dots[node.String()] = typ.String()
+	}
+	assert.Equal(t, dots[".Value"], "github.com/motemen/go-template-statictools/templatetypes.Dot1ContainedValue")
+
+	objects := map[string][]string{}
+	for node, objs := range s.Info.Objects {
+		for _, obj := range objs {
+			name := "<nil>"
+			if obj != nil {
+				name = obj.Name()
+			}
+			objects[node.String()] = append(objects[node.String()], name)
+		}
+	}
+	assert.DeepEqual(t, objects["$m.k.Value"], []string{"<nil>", "<nil>", "Value"})
+}
+
+func TestTypeAt(t *testing.T) {
+	source := `{{/* @type github.com/motemen/go-template-statictools/templatetypes.Dot1 */}}
+{{range .Slice}} in {{.Value}}{{end}} after
+{{define "inner"}}{{.InnerField}}{{end}}
+{{template "inner" .Inner}}{{(.Ptr.PtrMethod "x").Array}}`
+
+	s := Checker{Info: &Info{}, Tests: true}
+	err := s.Parse("page", strings.NewReader(source))
+	assert.NilError(t, err)
+	assert.NilError(t, s.Check("page"))
+
+	typeString := func(typ types.Type) string {
+		if typ == nil {
+			return "<nil>"
+		}
+		return types.TypeString(typ, func(*types.Package) string { return "" })
+	}
+
+	tests := []struct {
+		at  string // offset is of the first occurrence of at in source
+		dot string
+		typ string
+	}{
+		{"{{/*", "<nil>", "<nil>"},
+		{".Slice", "Dot1", "[]Dot1ContainedValue"},
+		{" in ", "Dot1ContainedValue", "<nil>"},
+		{"Value}}", "Dot1ContainedValue", "bool"},
+		{" after", "Dot1", "<nil>"},
+		{"InnerField", "Dot1Inner", "int"},
+		{".Inner}}", "Dot1", "Dot1Inner"},
+		{"PtrMethod", "Dot1", "Dot1Inner"},
+		{`"x"`, "Dot1", "string"},
+		{"Array", "Dot1", "[3]int"},
+	}
+
+	for _, test := range tests {
+		offset := strings.Index(source, test.at)
+		assert.Assert(t, offset >= 0, test.at)
+
+		dot, typ := s.TypeAt("page", offset)
+		assert.Equal(t, typeString(dot), test.dot, "at %q", test.at)
+		assert.Equal(t, typeString(typ), test.typ, "at %q", test.at)
+	}
+}
+
+func TestFindBindings(t *testing.T) {
+	s := Checker{Tests: true}
+	bindings, err := s.FindBindings("github.com/motemen/go-template-statictools/templatetypes/testdata/bindings")
+	assert.NilError(t, err)
+
+	var got []string
+	for _, b := range bindings {
+		got = append(got, fmt.Sprintf("%s:%d %q %v html=%v", filepath.Base(b.Pos.Filename), b.Pos.Line, b.Template, b.Dot, b.HTML))
+	}
+	assert.DeepEqual(t, got, []string{
+		`bindings.go:16 "page.tmpl" github.com/motemen/go-template-statictools/templatetypes/testdata/bindings.Page html=false`,
+		`bindings.go:20 "page.tmpl" *github.com/motemen/go-template-statictools/templatetypes/testdata/bindings.Page html=false`,
+		`bindings.go:26 "const.tmpl" int html=false`,
+		`bindings.go:30 "any.tmpl" <nil> html=false`,
+		`bindings.go:39 "" map[string]string html=false`,
+		`bindings.go:45 "page.html" <nil> html=true`,
+	})
+}
+
+func TestCheckBinding(t *testing.T) {
+	s := Checker{Tests: true}
+	bindings, err := s.FindBindings("github.com/motemen/go-template-statictools/templatetypes/testdata/bindings")
+	assert.NilError(t, err)
+
+	assert.NilError(t, s.Parse("templates/page.tmpl", strings.NewReader(`{{.Title}} {{.Body}}`)))
+	assert.NilError(t, s.Parse("templates/const.tmpl", strings.NewReader(`{{. | printf "%d"}}`)))
+	assert.NilError(t, s.Parse("templates/any.tmpl", strings.NewReader(`{{.Anything}}`)))
+
+	tests := []struct {
+		line         int
+		errorMessage string
+	}{
+		{16, "can't evaluate field Body in type github.com/motemen/go-template-statictools/templatetypes/testdata/bindings.Page"},
+		{20, "can't evaluate field Body in type *github.com/motemen/go-template-statictools/templatetypes/testdata/bindings.Page"},
+		{26, ""},
+		{30, ""},
+		{39, "template name is unknown"},
+		{45, `template "page.html" not found`},
+	}
+
+	for _, test := range tests {
+		t.Run(fmt.Sprint(test.line), func(t *testing.T) {
+			var binding *Binding
+			for i := range bindings {
+				if bindings[i].Pos.Line == test.line {
+					binding = &bindings[i]
+				}
+			}
+			assert.Assert(t, binding != nil)
+
+			err := s.CheckBinding(*binding)
+			if test.errorMessage == "" {
+				assert.NilError(t, err)
+			} else {
+				assert.ErrorContains(t, err, test.errorMessage)
+			}
+		})
+	}
+}
+
+func TestFindTemplateSets(t *testing.T) {
+	s := Checker{Tests: true}
+	sets, err := s.FindTemplateSets("github.com/motemen/go-template-statictools/templatetypes/testdata/sets")
+	assert.ErrorContains(t, err, "cannot resolve template file: must be a constant string")
+
+	var got []string
+	for _, set := range sets {
+		var files []string
+		for _, file := range set.Files {
+			files = append(files, filepath.Base(file))
+		}
+		got = append(got, fmt.Sprintf("%d %s %v %q %q html=%v funcmaps=%d", set.Pos.Line, set.Name, files, set.LeftDelim, set.RightDelim, set.HTML, len(set.funcMaps)))
+	}
+	assert.DeepEqual(t, got, []string{
+		`23 page.tmpl [page.tmpl partial.tmpl] "" "" html=false funcmaps=1`,
+		`25 delims.tmpl [delims.tmpl page.tmpl partial.tmpl] "" "" html=false funcmaps=0`,
+		`27 index.html [index.html] "" "" html=true funcmaps=0`,
+		`35 delims.tmpl [delims.tmpl] "[[" "]]" html=false funcmaps=1`,
+		`57 index.html [index.html] "" "" html=true funcmaps=0`,
+	})
+}
+
+func TestTemplateSetChecker(t *testing.T) {
+	const pkg = "github.com/motemen/go-template-statictools/templatetypes/testdata/sets"
+
+	s := Checker{Tests: true}
+	sets, _ := s.FindTemplateSets(pkg)
+	assert.Equal(t, len(sets), 5)
+	bindings, err := s.FindBindings(pkg)
+	assert.NilError(t, err)
+
+	tests := []struct {
+		name         string
+		set          TemplateSet
+		entryPoint   string
+		errorMessage string
+	}{
+		{"FuncMap", sets[0], "page.tmpl", ""},
+		{"no FuncMap", sets[1], "partial.tmpl", `function "upper" not found`},
+		{"Delims and local FuncMap", sets[3], "delims.tmpl", "function lower: wrong type for argument 1: expected string; got untyped int"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c, err := s.TemplateSetChecker(&test.set)
+			assert.NilError(t, err)
+
+			err = c.Check(test.entryPoint)
+			if test.errorMessage == "" {
+				assert.NilError(t, err)
+			} else {
+				assert.Error(t, err, test.errorMessage)
+			}
+		})
+	}
+
+	t.Run("bindings", func(t *testing.T) {
+		var checked int
+		for _, b := range bindings {
+			if b.Set == nil {
+				continue
+			}
+			for i := range sets {
+				if sets[i].Pos != b.Set.Pos {
+					continue
+				}
+				c, err := s.TemplateSetChecker(&sets[i])
+				assert.NilError(t, err)
+				assert.NilError(t, c.CheckBinding(b))
+				checked++
+			}
+		}
+		assert.Equal(t, checked, 2)
+	})
+}
+
+func TestCheckTemplateSetConfigured(t *testing.T) {
+	const pkg = "github.com/motemen/go-template-statictools/templatetypes/testdata/sets"
+	files := []string{"testdata/sets/templates/page.tmpl", "testdata/sets/templates/partial.tmpl"}
+
+	sets := []TemplateSet{
+		{Name: "page", Files: files, DotType: pkg + ".Page", FuncMapVar: pkg + ".funcs", EntryPoints: []string{"page.tmpl"}},
+		{Name: "no FuncMap", Files: files, DotType: pkg + ".Page", EntryPoints: []string{"page.tmpl"}},
+		{Name: "other dot", Files: files, DotType: "github.com/motemen/go-template-statictools/example.Item", FuncMapVar: pkg + ".funcs", EntryPoints: []string{"page.tmpl"}},
+		{Name: "no entry point", Files: files, EntryPoints: []string{"index.html"}},
+		// each page defines "content" for the layout, which do not collide
+		{Name: "home", Files: []string{"testdata/sets/pages/layout.tmpl", "testdata/sets/pages/home.tmpl"}, DotType: pkg + ".Page", EntryPoints: []string{"layout.tmpl"}},
+		{Name: "item", Files: []string{"testdata/sets/pages/layout.tmpl", "testdata/sets/pages/item.tmpl"}, DotType: "github.com/motemen/go-template-statictools/example.Item", EntryPoints: []string{"layout.tmpl"}},
+		// as opposed to a set of all of them, where the last one wins
+		{Name: "all pages", Files: []string{"testdata/sets/pages/layout.tmpl", "testdata/sets/pages/home.tmpl", "testdata/sets/pages/item.tmpl"}, DotType: pkg + ".Page", EntryPoints: []string{"layout.tmpl"}},
+	}
+	errorMessages := []string{
+		"",
+		`function "upper" not found`,
+		"can't evaluate field Title in type github.com/motemen/go-template-statictools/example.Item",
+		`entry point "index.html" not found`,
+		"",
+		"",
+		"can't evaluate field Name in type " + pkg + ".Page",
+	}
+
+	s := Checker{Tests: true}
+	assert.NilError(t, s.LoadPackages(sets))
+
+	for i := range sets {
+		t.Run(sets[i].Name, func(t *testing.T) {
+			c, err := s.CheckTemplateSet(&sets[i], nil)
+			assert.Assert(t, c != nil)
+			if errorMessages[i] == "" {
+				assert.NilError(t, err)
+			} else {
+				assert.ErrorContains(t, err, errorMessages[i])
+			}
+		})
+	}
+}
@@ -0,0 +1,46 @@
+package bindings
+
+import (
+	htmltemplate "html/template"
+	"io"
+	"text/template"
+)
+
+var tmpl = template.Must(template.New("").Parse(""))
+
+type Page struct {
+	Title string
+}
+
+func Render(w io.Writer) error {
+	return tmpl.ExecuteTemplate(w, "page.tmpl", Page{Title: "title"})
+}
+
+func RenderPtr(w io.Writer) error {
+	return tmpl.ExecuteTemplate(w, "page.tmpl", &Page{})
+}
+
+const constName = "const.tmpl"
+
+func RenderConst(w io.Writer) error {
+	return tmpl.ExecuteTemplate(w, constName, 1)
+}
+
+func RenderAny(w io.Writer, data any) error {
+	return tmpl.ExecuteTemplate(w, "any.tmpl", data)
+}
+
+// the template name cannot be resolved statically
+func RenderDynamic(w io.Writer, name string) error {
+	return tmpl.ExecuteTemplate(w, name, Page{})
+}
+
+func RenderExecute(w io.Writer) error {
+	return tmpl.Execute(w, map[string]string{})
+}
+
+var htmlTmpl = htmltemplate.Must(htmltemplate.New("").Parse(""))
+
+func RenderHTML(w io.Writer) error {
+	return htmlTmpl.ExecuteTemplate(w, "page.html", nil)
+}
@@ -0,0 +1,88 @@
+// Package funcmaps has FuncMaps built in various ways, for testing.
+package funcmaps
+
+import (
+	htmltemplate "html/template"
+	"strings"
+	"text/template"
+
+	"github.com/motemen/go-template-statictools/templatetypes/testdata/funcmaps/other"
+)
+
+const keyUpper = "upper"
+
+var Literal = template.FuncMap{
+	keyUpper:        strings.ToUpper,
+	"low" + "er":    strings.ToLower,
+	other.KeyRepeat: strings.Repeat,
+}
+
+var FromFunc = newFuncMap()
+
+func newFuncMap() template.FuncMap {
+	m := template.FuncMap{
+		"upper": strings.ToUpper,
+	}
+	m["repeat"] = strings.Repeat
+	return m
+}
+
+var FromInit template.FuncMap
+
+func init() {
+	FromInit = template.FuncMap{
+		"upper": strings.ToUpper,
+	}
+	FromInit["trim"] = strings.TrimSpace
+}
+
+func init() {
+	// runs after InitOrder in initorder.go is initialized
+	InitOrder["upper"] = strings.ToUpper
+}
+
+var FromOtherPackage = other.FuncMap()
+
+var FromVar = template.FuncMap(Literal)
+
+var Merged = template.FuncMap{
+	"upper": func(n int) int { return n },
+}
+
+var NonConstantKey = template.FuncMap{
+	key(): strings.ToUpper,
+}
+
+func key() string {
+	return "upper"
+}
+
+var NotAFunction = template.FuncMap{
+	"upper": "upper",
+}
+
+var FromParam = fromParam(nil)
+
+func fromParam(m template.FuncMap) template.FuncMap {
+	return m
+}
+
+var HTMLFuncMap = htmltemplate.FuncMap{
+	"upper": strings.ToUpper,
+}
+
+var PlainMap = map[string]any{
+	"upper": strings.ToUpper,
+}
+
+var PlainMapInterface = map[string]interface{}{
+	"upper": strings.ToUpper,
+}
+
+var NotFuncMap = map[string]string{
+	"upper": "upper",
+}
+
+var Override = template.FuncMap{
+	"upper": func(n int) int { return n },
+}
@@ -0,0 +1,7 @@
+package funcmaps
+
+import "text/template"
+
+var InitOrder = template.FuncMap{
+	"upper": func(n int) int { return n },
+}
@@ -0,0 +1,9 @@
+//go:build go1.21
+
+package funcmaps
+
+import "maps"
+
+func init() {
+	maps.Copy(Merged, FromFunc)
+}
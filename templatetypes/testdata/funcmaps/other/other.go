@@ -0,0 +1,16 @@
+// Package other provides a FuncMap to package funcmaps, for testing.
+package other
+
+import (
+	"strings"
+	"text/template"
+)
+
+const KeyRepeat = "repeat"
+
+func FuncMap() template.FuncMap {
+	return template.FuncMap{
+		"upper":   strings.ToUpper,
+		KeyRepeat: strings.Repeat,
+	}
+}
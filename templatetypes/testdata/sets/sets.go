@@ -0,0 +1,57 @@
+package sets
+
+import (
+	"embed"
+	htmltemplate "html/template"
+	"io"
+	"io/fs"
+	"strings"
+	"text/template"
+)
+
+//go:embed templates
+var templatesFS embed.FS
+
+type Page struct {
+	Title string
+}
+
+var funcs = template.FuncMap{
+	"upper": strings.ToUpper,
+}
+
+var pages = template.Must(template.New("page.tmpl").Funcs(funcs).ParseFiles("templates/page.tmpl", "templates/partial.tmpl"))
+
+var globbed = template.Must(template.ParseGlob("templates/*.tmpl"))
+
+var embedded = htmltemplate.Must(htmltemplate.ParseFS(templatesFS, "templates/*.html"))
+
+func newDelims() *template.Template {
+	sub, err := fs.Sub(templatesFS, "templates")
+	if err != nil {
+		panic(err)
+	}
+
+	t := template.New("delims.tmpl").Delims("[[", "]]")
+	t.Funcs(template.FuncMap{"lower": strings.ToLower})
+	template.Must(t.ParseFS(sub, "delims.tmpl"))
+	return t
+}
+
+func dynamic(name string) *template.Template {
+	return template.Must(template.ParseFiles(name))
+}
+
+func RenderPage(w io.Writer) error {
+	return pages.ExecuteTemplate(w, "page.tmpl", Page{Title: "title"})
+}
+
+func RenderIndex(w io.Writer) error {
+	return embedded.Execute(w, "/")
+}
+
+//go:embed templates/*.html
+var htmlFS embed.FS
+
+// templates/*.tmpl exist on disk, but are not embedded in htmlFS
+var embeddedOnly = htmltemplate.Must(htmltemplate.ParseFS(htmlFS, "templates/*"))